@@ -0,0 +1,338 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+// #include <stdlib.h>
+// #include "libmobilecoin.h"
+import "C"
+
+const (
+	// ATTEST_AEAD_TAG_SIZE is the tag the AES-256-GCM cipher of an attested
+	// session adds to every message.
+	ATTEST_AEAD_TAG_SIZE = 16
+
+	// ATTEST_AUTH_REQUEST_MAX_SIZE bounds the first message of the key
+	// exchange, a few public keys and tags.
+	ATTEST_AUTH_REQUEST_MAX_SIZE = 4096
+)
+
+// AttestVerifier checks the IAS report of an enclave, the enclave must
+// match one of the added MRENCLAVE or MRSIGNER.
+type AttestVerifier struct {
+	mutex    sync.Mutex
+	verifier *C.McVerifier
+	enclaves []*C.McMrEnclaveVerifier
+	signers  []*C.McMrSignerVerifier
+}
+
+func NewAttestVerifier() (*AttestVerifier, error) {
+	verifier := C.mc_verifier_create()
+	if verifier == nil {
+		return nil, errors.New("mc_verifier_create failed")
+	}
+	v := &AttestVerifier{verifier: verifier}
+	runtime.SetFinalizer(v, (*AttestVerifier).Close)
+	return v, nil
+}
+
+// AddMrEnclave accepts the enclave measurement mrEnclave, the advisories
+// are the IDs the enclave author says are already addressed,
+// e.g. INTEL-SA-00334.
+func (v *AttestVerifier) AddMrEnclave(mrEnclave [32]byte, configAdvisories, hardeningAdvisories []string) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if v.verifier == nil {
+		return errors.New("AttestVerifier is closed")
+	}
+
+	c_mr_enclave := newMcBuffer(mrEnclave[:])
+	defer freeMcBuffer(c_mr_enclave)
+	mr_enclave_verifier := C.mc_mr_enclave_verifier_create(&c_mr_enclave)
+	if mr_enclave_verifier == nil {
+		return errors.New("mc_mr_enclave_verifier_create failed")
+	}
+	v.enclaves = append(v.enclaves, mr_enclave_verifier)
+
+	for _, id := range configAdvisories {
+		c_advisory_id := C.CString(id)
+		ret := C.mc_mr_enclave_verifier_allow_config_advisory(mr_enclave_verifier, c_advisory_id)
+		C.free(unsafe.Pointer(c_advisory_id))
+		if ret == false {
+			return errors.New("mc_mr_enclave_verifier_allow_config_advisory failed")
+		}
+	}
+	for _, id := range hardeningAdvisories {
+		c_advisory_id := C.CString(id)
+		ret := C.mc_mr_enclave_verifier_allow_hardening_advisory(mr_enclave_verifier, c_advisory_id)
+		C.free(unsafe.Pointer(c_advisory_id))
+		if ret == false {
+			return errors.New("mc_mr_enclave_verifier_allow_hardening_advisory failed")
+		}
+	}
+
+	if C.mc_verifier_add_mr_enclave(v.verifier, mr_enclave_verifier) == false {
+		return errors.New("mc_verifier_add_mr_enclave failed")
+	}
+	return nil
+}
+
+// AddMrSigner accepts the enclaves signed by mrSigner with productID and at
+// least minimumSecurityVersion.
+func (v *AttestVerifier) AddMrSigner(mrSigner [32]byte, productID, minimumSecurityVersion uint16, configAdvisories, hardeningAdvisories []string) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if v.verifier == nil {
+		return errors.New("AttestVerifier is closed")
+	}
+
+	c_mr_signer := newMcBuffer(mrSigner[:])
+	defer freeMcBuffer(c_mr_signer)
+	mr_signer_verifier := C.mc_mr_signer_verifier_create(&c_mr_signer, C.uint16_t(productID), C.uint16_t(minimumSecurityVersion))
+	if mr_signer_verifier == nil {
+		return errors.New("mc_mr_signer_verifier_create failed")
+	}
+	v.signers = append(v.signers, mr_signer_verifier)
+
+	for _, id := range configAdvisories {
+		c_advisory_id := C.CString(id)
+		ret := C.mc_mr_signer_verifier_allow_config_advisory(mr_signer_verifier, c_advisory_id)
+		C.free(unsafe.Pointer(c_advisory_id))
+		if ret == false {
+			return errors.New("mc_mr_signer_verifier_allow_config_advisory failed")
+		}
+	}
+	for _, id := range hardeningAdvisories {
+		c_advisory_id := C.CString(id)
+		ret := C.mc_mr_signer_verifier_allow_hardening_advisory(mr_signer_verifier, c_advisory_id)
+		C.free(unsafe.Pointer(c_advisory_id))
+		if ret == false {
+			return errors.New("mc_mr_signer_verifier_allow_hardening_advisory failed")
+		}
+	}
+
+	if C.mc_verifier_add_mr_signer(v.verifier, mr_signer_verifier) == false {
+		return errors.New("mc_verifier_add_mr_signer failed")
+	}
+	return nil
+}
+
+func (v *AttestVerifier) Close() error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	for _, e := range v.enclaves {
+		C.mc_mr_enclave_verifier_free(e)
+	}
+	for _, s := range v.signers {
+		C.mc_mr_signer_verifier_free(s)
+	}
+	C.mc_verifier_free(v.verifier)
+	v.enclaves, v.signers, v.verifier = nil, nil, nil
+	return nil
+}
+
+// AttestAke is the AttestedConnection of libmobilecoin, the same key
+// exchange attests the consensus, fog view and fog ledger enclaves. It is
+// safe for concurrent use, the messages are encrypted in order.
+type AttestAke struct {
+	mutex    sync.Mutex
+	ake      *C.McAttestAke
+	verifier *AttestVerifier
+}
+
+var _ AttestedConnection = (*AttestAke)(nil)
+
+// NewAttestAke starts a key exchange, the auth response of the enclave is
+// checked by verifier, which must stay open until then.
+func NewAttestAke(verifier *AttestVerifier) (*AttestAke, error) {
+	ake := C.mc_attest_ake_create()
+	if ake == nil {
+		return nil, errors.New("mc_attest_ake_create failed")
+	}
+	a := &AttestAke{ake: ake, verifier: verifier}
+	runtime.SetFinalizer(a, (*AttestAke).Close)
+	return a, nil
+}
+
+// NewAttestAkeFunc makes an AttestAke for every attestation of a
+// ConsensusClient.
+func NewAttestAkeFunc(verifier *AttestVerifier) func() (AttestedConnection, error) {
+	return func() (AttestedConnection, error) {
+		return NewAttestAke(verifier)
+	}
+}
+
+// AuthRequest is the data of the attest.AuthMessage sent to the node
+// responderID, host:port.
+func (a *AttestAke) AuthRequest(responderID string) ([]byte, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.ake == nil {
+		return nil, errors.New("AttestAke is closed")
+	}
+
+	c_responder_id := C.CString(responderID)
+	defer C.free(unsafe.Pointer(c_responder_id))
+	// a single call, every call starts a new handshake
+	out := newMcMutableBuffer(ATTEST_AUTH_REQUEST_MAX_SIZE)
+	defer freeMcMutableBuffer(out)
+	size := C.mc_attest_ake_get_auth_request(a.ake, c_responder_id, nil, &out)
+	if size < 0 || int(size) > ATTEST_AUTH_REQUEST_MAX_SIZE {
+		return nil, errors.New("mc_attest_ake_get_auth_request failed")
+	}
+	return C.GoBytes(unsafe.Pointer(out.buffer), C.int(size)), nil
+}
+
+func (a *AttestAke) ProcessAuthResponse(response []byte) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.ake == nil {
+		return errors.New("AttestAke is closed")
+	}
+	a.verifier.mutex.Lock()
+	defer a.verifier.mutex.Unlock()
+	if a.verifier.verifier == nil {
+		return errors.New("AttestVerifier is closed")
+	}
+
+	c_response := newMcBuffer(response)
+	defer freeMcBuffer(c_response)
+	var mc_error *C.McError
+	if C.mc_attest_ake_process_auth_response(a.ake, &c_response, a.verifier.verifier, &mc_error) == false {
+		return newMcError("mc_attest_ake_process_auth_response", mc_error)
+	}
+	return nil
+}
+
+func (a *AttestAke) IsAttested() (bool, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.ake == nil {
+		return false, errors.New("AttestAke is closed")
+	}
+
+	var attested C.bool
+	if C.mc_attest_ake_is_attested(a.ake, &attested) == false {
+		return false, errors.New("mc_attest_ake_is_attested failed")
+	}
+	return bool(attested), nil
+}
+
+// Binding is the channel ID of the attested session.
+func (a *AttestAke) Binding() ([]byte, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.ake == nil {
+		return nil, errors.New("AttestAke is closed")
+	}
+
+	size := C.mc_attest_ake_get_binding(a.ake, nil)
+	if size < 0 {
+		return nil, errors.New("mc_attest_ake_get_binding failed")
+	}
+	out := newMcMutableBuffer(int(size))
+	defer freeMcMutableBuffer(out)
+	size = C.mc_attest_ake_get_binding(a.ake, &out)
+	if size < 0 {
+		return nil, errors.New("mc_attest_ake_get_binding failed")
+	}
+	return C.GoBytes(unsafe.Pointer(out.buffer), C.int(size)), nil
+}
+
+func (a *AttestAke) Encrypt(aad, plaintext []byte) ([]byte, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.ake == nil {
+		return nil, errors.New("AttestAke is closed")
+	}
+
+	c_aad := newMcBuffer(aad)
+	defer freeMcBuffer(c_aad)
+	c_plaintext := newMcBuffer(plaintext)
+	defer freeMcBuffer(c_plaintext)
+	// a single call, every call advances the nonce of the session
+	out := newMcMutableBuffer(len(plaintext) + ATTEST_AEAD_TAG_SIZE)
+	defer freeMcMutableBuffer(out)
+	var mc_error *C.McError
+	size := C.mc_attest_ake_encrypt(a.ake, &c_aad, &c_plaintext, &out, &mc_error)
+	if size < 0 {
+		return nil, newMcError("mc_attest_ake_encrypt", mc_error)
+	}
+	if int(size) > len(plaintext)+ATTEST_AEAD_TAG_SIZE {
+		return nil, fmt.Errorf("mc_attest_ake_encrypt wrote %d bytes", size)
+	}
+	return C.GoBytes(unsafe.Pointer(out.buffer), C.int(size)), nil
+}
+
+func (a *AttestAke) Decrypt(aad, ciphertext []byte) ([]byte, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.ake == nil {
+		return nil, errors.New("AttestAke is closed")
+	}
+
+	c_aad := newMcBuffer(aad)
+	defer freeMcBuffer(c_aad)
+	c_ciphertext := newMcBuffer(ciphertext)
+	defer freeMcBuffer(c_ciphertext)
+	// the plaintext is never longer than the ciphertext
+	out := newMcMutableBuffer(len(ciphertext))
+	defer freeMcMutableBuffer(out)
+	var mc_error *C.McError
+	size := C.mc_attest_ake_decrypt(a.ake, &c_aad, &c_ciphertext, &out, &mc_error)
+	if size < 0 {
+		return nil, newMcError("mc_attest_ake_decrypt", mc_error)
+	}
+	return C.GoBytes(unsafe.Pointer(out.buffer), C.int(size)), nil
+}
+
+func (a *AttestAke) Close() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	C.mc_attest_ake_free(a.ake)
+	a.ake = nil
+	return nil
+}
+
+// newMcBuffer copies data to C memory, which is never NULL even for empty
+// data.
+func newMcBuffer(data []byte) C.McBuffer {
+	return C.McBuffer{
+		buffer: (*C.uchar)(C.CBytes(data)),
+		len:    C.size_t(len(data)),
+	}
+}
+
+func freeMcBuffer(buf C.McBuffer) {
+	C.free(unsafe.Pointer(buf.buffer))
+}
+
+func newMcMutableBuffer(size int) C.McMutableBuffer {
+	return C.McMutableBuffer{
+		buffer: (*C.uchar)(C.malloc(C.size_t(size + 1))),
+		len:    C.size_t(size),
+	}
+}
+
+func freeMcMutableBuffer(buf C.McMutableBuffer) {
+	C.free(unsafe.Pointer(buf.buffer))
+}
+
+// newMcError takes the ownership of mc_error, it may be NULL when the
+// function failed a precondition.
+func newMcError(function string, mc_error *C.McError) error {
+	if mc_error == nil {
+		return fmt.Errorf("%s failed", function)
+	}
+	defer C.mc_error_free(mc_error)
+	return &McError{
+		Function:    function,
+		Code:        McErrorCode(mc_error.error_code),
+		Description: C.GoString(mc_error.error_description),
+	}
+}
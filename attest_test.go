@@ -0,0 +1,38 @@
+//go:build cgo
+// +build cgo
+
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttestAke(t *testing.T) {
+	assert := assert.New(t)
+
+	signature, err := ParseSignature()
+	assert.Nil(err)
+	verifier, err := NewAttestVerifier()
+	assert.Nil(err)
+	defer verifier.Close()
+	assert.Nil(verifier.AddMrEnclave(signature.MRENCLAVE(), nil, []string{"INTEL-SA-00334"}))
+
+	ake, err := NewAttestAke(verifier)
+	assert.Nil(err)
+	attested, err := ake.IsAttested()
+	assert.Nil(err)
+	assert.False(attested)
+	request, err := ake.AuthRequest("node1.prod.mobilecoinww.com:443")
+	assert.Nil(err)
+	assert.NotEmpty(request)
+
+	err = ake.ProcessAuthResponse([]byte{1, 2, 3})
+	assert.NotNil(err)
+
+	assert.Nil(ake.Close())
+	_, err = ake.AuthRequest("node1.prod.mobilecoinww.com:443")
+	assert.NotNil(err)
+	assert.Nil(ake.Close())
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	account "github.com/jadeydi/mobilecoin-account"
+)
+
+type BatchPaymentOptions struct {
+	// Fee is paid by every transaction of the batch, when it is 0 each
+	// transaction pays the estimate of FeeEstimator.
+	Fee            uint64
+	FeeEstimator   FeeEstimator
+	TombstoneBlock uint64
+	ChangeAddress  *account.PublicAddress
+	Strategy       SelectionStrategy
+	// InputCredential builds the ring and credential spending utxo, it is
+	// only needed by BuildBatchPayment.
+	InputCredential func(utxo *UnspentTxOut) (*InputCredential, error)
+	Rand            io.Reader
+}
+
+// BatchTransactionPlan is one transaction of a batch, OutlayIndices are
+// indices in the outlays given to the batch, in the order of the
+// transaction OutlayList.
+type BatchTransactionPlan struct {
+	OutlayIndices []int
+	Inputs        []*UnspentTxOut
+	Value         uint64
+	Fee           uint64
+	Change        uint64
+}
+
+type BatchPaymentPlan struct {
+	Transactions []*BatchTransactionPlan
+	// OutlayTransaction is the index of the transaction paying each outlay.
+	OutlayTransaction []int
+	Fee               uint64
+	Change            uint64
+}
+
+// PlanBatchPayment packs the outlays, in their order, into as few
+// transactions as possible. Every transaction keeps an output for its own
+// change, so it pays at most MAX_OUTPUTS-1 outlays, and spends inputs
+// which no other transaction of the batch spends. The change of a
+// transaction is not spendable before it is in a block, so it is never
+// used as an input of the same batch.
+func PlanBatchPayment(outlays []*Outlay, utxos []*UnspentTxOut, opts *BatchPaymentOptions) (*BatchPaymentPlan, error) {
+	if opts == nil || opts.ChangeAddress == nil {
+		return nil, errors.New("Invalid batch payment options, change address required")
+	}
+	if opts.Fee == 0 && opts.FeeEstimator == nil {
+		return nil, errors.New("Invalid batch payment options, fee required")
+	}
+	if len(outlays) == 0 {
+		return nil, errors.New("Invalid batch payment, no outlays")
+	}
+	values := make([]uint64, len(outlays))
+	for i, outlay := range outlays {
+		if outlay == nil || outlay.Receiver == nil {
+			return nil, fmt.Errorf("Invalid outlay %d", i)
+		}
+		value, err := strconv.ParseUint(outlay.Value, 10, 64)
+		if err != nil || value == 0 {
+			return nil, fmt.Errorf("Invalid outlay %d value %s", i, outlay.Value)
+		}
+		values[i] = value
+	}
+
+	plan := &BatchPaymentPlan{OutlayTransaction: make([]int, len(outlays))}
+	remaining := append([]*UnspentTxOut{}, utxos...)
+	for start := 0; start < len(outlays); {
+		end := start + MAX_OUTPUTS - 1
+		if end > len(outlays) {
+			end = len(outlays)
+		}
+
+		var selection *Selection
+		for ; end > start; end-- {
+			var target uint64
+			for _, value := range values[start:end] {
+				if target+value < target {
+					return nil, errors.New("Invalid outlay values overflow")
+				}
+				target += value
+			}
+			var err error
+			selection, err = opts.selectInputs(remaining, target, end-start+1)
+			if err == nil {
+				break
+			}
+			// fewer outlays may still fit in MAX_INPUTS
+			if !errors.Is(err, ErrTooFragmented) {
+				return nil, err
+			}
+		}
+		if selection == nil {
+			return nil, ErrTooFragmented
+		}
+
+		tx := &BatchTransactionPlan{
+			Inputs: selection.Inputs,
+			Value:  selection.Target,
+			Fee:    selection.Fee,
+			Change: selection.Change(),
+		}
+		for i := start; i < end; i++ {
+			tx.OutlayIndices = append(tx.OutlayIndices, i)
+			plan.OutlayTransaction[i] = len(plan.Transactions)
+		}
+		plan.Transactions = append(plan.Transactions, tx)
+		plan.Fee += tx.Fee
+		plan.Change += tx.Change
+		remaining = removeUnspentTxOuts(remaining, selection.Inputs)
+		start = end
+	}
+	return plan, nil
+}
+
+// BuildBatchPayment builds and signs the transactions of PlanBatchPayment,
+// the TxProposal at index i is the transaction i of the plan.
+func BuildBatchPayment(outlays []*Outlay, utxos []*UnspentTxOut, opts *BatchPaymentOptions) ([]*TxProposal, *BatchPaymentPlan, error) {
+	if opts == nil || opts.InputCredential == nil {
+		return nil, nil, errors.New("Invalid batch payment options, input credential required")
+	}
+	plan, err := PlanBatchPayment(outlays, utxos, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	proposals := make([]*TxProposal, len(plan.Transactions))
+	for i, tx := range plan.Transactions {
+		tb := NewTransactionBuilder(&TransactionBuilderOptions{
+			Fee:            tx.Fee,
+			TombstoneBlock: opts.TombstoneBlock,
+			ChangeAddress:  opts.ChangeAddress,
+			OmitZeroChange: true,
+			Rand:           opts.Rand,
+		})
+		inputs := make(map[CompressedRistretto]*UnspentTxOut, len(tx.Inputs))
+		for _, utxo := range tx.Inputs {
+			input, err := opts.InputCredential(utxo)
+			if err != nil {
+				return nil, nil, err
+			}
+			tb.AddInput(input)
+			inputs[utxo.TxOut.PublicKey] = utxo
+		}
+		for _, index := range tx.OutlayIndices {
+			value, _ := strconv.ParseUint(outlays[index].Value, 10, 64)
+			tb.AddOutput(value, outlays[index].Receiver)
+		}
+		proposal, err := tb.BuildTxProposal()
+		if err != nil {
+			return nil, nil, fmt.Errorf("Batch transaction %d %w", i, err)
+		}
+		// keep the wallet fields of the spent outputs
+		for j, input := range proposal.InputList {
+			if utxo, ok := inputs[input.TxOut.PublicKey]; ok {
+				proposal.InputList[j] = utxo
+			}
+		}
+		proposals[i] = proposal
+	}
+	return proposals, plan, nil
+}
+
+// selectInputs grows the fee estimate with the selected inputs until the
+// selection pays for itself.
+func (opts *BatchPaymentOptions) selectInputs(utxos []*UnspentTxOut, target uint64, numOutputs int) (*Selection, error) {
+	for numInputs := 1; ; {
+		fee, err := opts.fee(numInputs, numOutputs)
+		if err != nil {
+			return nil, err
+		}
+		selection, err := SelectInputsWithRNG(utxos, target, fee, opts.Strategy, opts.rng())
+		if err != nil {
+			return nil, err
+		}
+		if len(selection.Inputs) <= numInputs {
+			return selection, nil
+		}
+		numInputs = len(selection.Inputs)
+	}
+}
+
+func (opts *BatchPaymentOptions) fee(numInputs, numOutputs int) (uint64, error) {
+	if opts.Fee > 0 {
+		return opts.Fee, nil
+	}
+	return opts.FeeEstimator.EstimateFee(numInputs, numOutputs)
+}
+
+func (opts *BatchPaymentOptions) rng() io.Reader {
+	if opts.Rand == nil {
+		return rand.Reader
+	}
+	return opts.Rand
+}
+
+func removeUnspentTxOuts(utxos, spent []*UnspentTxOut) []*UnspentTxOut {
+	removed := make(map[*UnspentTxOut]bool, len(spent))
+	for _, utxo := range spent {
+		removed[utxo] = true
+	}
+	var remaining []*UnspentTxOut
+	for _, utxo := range utxos {
+		if !removed[utxo] {
+			remaining = append(remaining, utxo)
+		}
+	}
+	return remaining
+}
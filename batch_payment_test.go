@@ -0,0 +1,124 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	account "github.com/jadeydi/mobilecoin-account"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildBatchPayment(t *testing.T) {
+	assert := assert.New(t)
+
+	acc, address := newTestAccount()
+	credentials := make(map[CompressedRistretto]*InputCredential)
+	var utxos []*UnspentTxOut
+	for i := 0; i < 4; i++ {
+		input := newTestInputCredential(acc, address, 10*MILLIMOB_TO_PICOMOB)
+		real := input.Ring[input.RealIndex]
+		credentials[real.PublicKey] = input
+		utxos = append(utxos, &UnspentTxOut{TxOut: real, Value: strconv.FormatUint(10*MILLIMOB_TO_PICOMOB, 10), MonitorId: "monitor"})
+	}
+
+	var outlays []*Outlay
+	recipients := make([]*account.Account, 20)
+	for i := range recipients {
+		var recipient *account.PublicAddress
+		recipients[i], recipient = newTestAccount()
+		outlays = append(outlays, &Outlay{Value: strconv.FormatUint(uint64(i+1)*MILLIMOB_TO_PICOMOB/10, 10), Receiver: recipient})
+	}
+
+	opts := &BatchPaymentOptions{
+		Fee:            MINIMUM_FEE,
+		TombstoneBlock: 150,
+		ChangeAddress:  address,
+		InputCredential: func(utxo *UnspentTxOut) (*InputCredential, error) {
+			return credentials[utxo.TxOut.PublicKey], nil
+		},
+	}
+	proposals, plan, err := BuildBatchPayment(outlays, utxos, opts)
+	assert.Nil(err)
+	assert.Len(proposals, 2)
+	assert.Len(plan.Transactions, 2)
+	assert.Len(plan.Transactions[0].OutlayIndices, MAX_OUTPUTS-1)
+	assert.Len(plan.Transactions[1].OutlayIndices, 20-MAX_OUTPUTS+1)
+	assert.Equal(uint64(2*MINIMUM_FEE), plan.Fee)
+
+	keyImages := make(map[string]bool)
+	var change uint64
+	for i, proposal := range proposals {
+		tx := plan.Transactions[i]
+		assert.Nil(ValidateTx(proposal.Tx, 100))
+		assert.Len(proposal.OutlayList, len(tx.OutlayIndices))
+		assert.Len(proposal.InputList, len(tx.Inputs))
+		for _, input := range proposal.InputList {
+			assert.Equal("monitor", input.MonitorId)
+		}
+		for _, signature := range proposal.Tx.Signature.RingSignatures {
+			assert.False(keyImages[signature.KeyImage])
+			keyImages[signature.KeyImage] = true
+		}
+		for j, index := range tx.OutlayIndices {
+			assert.Equal(i, plan.OutlayTransaction[index])
+			assert.Equal(outlays[index].Value, proposal.OutlayList[j].Value)
+			txOut := proposal.Tx.Prefix.Outputs[proposal.OutlayIndexToTxOutIndex[j][1]]
+			value, _ := GetValueWithBlinding(txOut, recipients[index].ViewPrivateKey)
+			assert.Equal(outlays[index].Value, strconv.FormatUint(value, 10))
+		}
+		outlayTxOuts := make(map[int]bool)
+		for _, indices := range proposal.OutlayIndexToTxOutIndex {
+			outlayTxOuts[indices[1]] = true
+		}
+		for j, txOut := range proposal.Tx.Prefix.Outputs {
+			if !outlayTxOuts[j] {
+				value, _ := GetValueWithBlinding(txOut, acc.ViewPrivateKey)
+				change += value
+			}
+		}
+	}
+	assert.Equal(plan.Change, change)
+
+	_, _, err = BuildBatchPayment(outlays, utxos, &BatchPaymentOptions{Fee: MINIMUM_FEE, ChangeAddress: address})
+	assert.NotNil(err)
+	_, err = PlanBatchPayment(outlays, utxos, &BatchPaymentOptions{Fee: MINIMUM_FEE})
+	assert.NotNil(err)
+	_, err = PlanBatchPayment([]*Outlay{{Value: "0", Receiver: address}}, utxos, opts)
+	assert.NotNil(err)
+}
+
+func TestPlanBatchPayment(t *testing.T) {
+	assert := assert.New(t)
+
+	_, address := newTestAccount()
+	values := make([]uint64, 40)
+	for i := range values {
+		values[i] = 10
+	}
+	utxos := newTestUnspentTxOuts(values...)
+	outlay := &Outlay{Value: "100", Receiver: address}
+	opts := &BatchPaymentOptions{Fee: 1, ChangeAddress: address}
+
+	// each outlay needs 11 inputs, only one fits in MAX_INPUTS
+	plan, err := PlanBatchPayment([]*Outlay{outlay, outlay, outlay}, utxos, opts)
+	assert.Nil(err)
+	assert.Len(plan.Transactions, 3)
+	assert.Equal([]int{0, 1, 2}, plan.OutlayTransaction)
+	spent := make(map[*UnspentTxOut]bool)
+	for _, tx := range plan.Transactions {
+		assert.Len(tx.Inputs, 11)
+		assert.Equal(uint64(9), tx.Change)
+		for _, utxo := range tx.Inputs {
+			assert.False(spent[utxo])
+			spent[utxo] = true
+		}
+	}
+	assert.Equal(uint64(3), plan.Fee)
+	assert.Equal(uint64(27), plan.Change)
+
+	_, err = PlanBatchPayment([]*Outlay{outlay, outlay, outlay, outlay}, utxos, opts)
+	assert.True(errors.Is(err, ErrInsufficientFunds))
+	_, err = PlanBatchPayment([]*Outlay{{Value: "200", Receiver: address}}, utxos, opts)
+	assert.True(errors.Is(err, ErrTooFragmented))
+}
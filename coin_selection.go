@@ -0,0 +1,273 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+)
+
+type SelectionStrategy int
+
+const (
+	// LargestFirst spends the fewest inputs.
+	LargestFirst SelectionStrategy = iota
+	// SmallestFirst spends small outputs first, keeping the wallet from
+	// fragmenting, but never more than MAX_INPUTS of them.
+	SmallestFirst
+	// BranchAndBound looks for a set of inputs matching target + fee exactly
+	// so no change output is needed, it falls back to LargestFirst.
+	BranchAndBound
+	// Randomized picks inputs in a random order so the selection does not
+	// leak the wallet composition, it falls back to LargestFirst when the
+	// random pick needs more than MAX_INPUTS.
+	Randomized
+)
+
+const branchAndBoundMaxTries = 100_000
+
+var (
+	// ErrInsufficientFunds is returned when all the outputs together are
+	// not worth target + fee.
+	ErrInsufficientFunds = errors.New("Insufficient funds")
+	// ErrTooFragmented is returned when the funds are enough but are spread
+	// over too many outputs to fit in MAX_INPUTS, they have to be
+	// consolidated first.
+	ErrTooFragmented = errors.New("Too fragmented, funds spread over more than MAX_INPUTS outputs")
+)
+
+type Selection struct {
+	Inputs []*UnspentTxOut
+	Target uint64
+	Fee    uint64
+	Total  uint64
+}
+
+// Change is the value left after paying target and fee.
+func (s *Selection) Change() uint64 {
+	return s.Total - s.Target - s.Fee
+}
+
+// InputCredentials turns the selected outputs into builder inputs, rings
+// holds the ring with the proofs of each selected output, the output among
+// its decoys, keyed by its public key, privateKey is the hex of the view and
+// spend private keys.
+func (s *Selection) InputCredentials(privateKey string, proofSet map[string]*TxOutMembershipProof, rings map[string][]*TxOutWithProof) ([]*InputCredential, error) {
+	if len(privateKey) != 128 {
+		return nil, fmt.Errorf("Invalid private key length %d", len(privateKey))
+	}
+	inputs := make([]*InputCredential, len(s.Inputs))
+	for i, utxo := range s.Inputs {
+		data, err := json.Marshal(utxo.TxOut)
+		if err != nil {
+			return nil, err
+		}
+		value, _ := unspentValue(utxo)
+		input, err := NewInputCredential(&UTXO{
+			Amount:          value,
+			PrivateKey:      privateKey,
+			ScriptPubKey:    hex.EncodeToString(data),
+			SubaddressIndex: utxo.SubaddressIndex,
+		}, proofSet, rings[utxo.TxOut.PublicKey.String()], privateKey[:64])
+		if err != nil {
+			return nil, err
+		}
+		inputs[i] = input
+	}
+	return inputs, nil
+}
+
+func SelectInputs(utxos []*UnspentTxOut, target, fee uint64, strategy SelectionStrategy) (*Selection, error) {
+	return SelectInputsWithRNG(utxos, target, fee, strategy, rand.Reader)
+}
+
+// SelectInputsWithRNG is SelectInputs with the randomness of the Randomized
+// strategy read from rng.
+func SelectInputsWithRNG(utxos []*UnspentTxOut, target, fee uint64, strategy SelectionStrategy, rng io.Reader) (*Selection, error) {
+	if target > math.MaxUint64-fee {
+		return nil, fmt.Errorf("Invalid target %d and fee %d", target, fee)
+	}
+	needed := target + fee
+
+	candidates := make([]*UnspentTxOut, 0, len(utxos))
+	values := make(map[*UnspentTxOut]uint64, len(utxos))
+	var total uint64
+	for _, utxo := range utxos {
+		value, err := unspentValue(utxo)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := values[utxo]; ok || value == 0 {
+			continue
+		}
+		if total > math.MaxUint64-value {
+			return nil, fmt.Errorf("Invalid total value overflow")
+		}
+		total += value
+		candidates = append(candidates, utxo)
+		values[utxo] = value
+	}
+	if total < needed || len(candidates) == 0 {
+		return nil, ErrInsufficientFunds
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return values[candidates[i]] > values[candidates[j]]
+	})
+	var largest uint64
+	for i := 0; i < len(candidates) && i < MAX_INPUTS; i++ {
+		largest += values[candidates[i]]
+	}
+	if largest < needed {
+		return nil, ErrTooFragmented
+	}
+
+	var inputs []*UnspentTxOut
+	switch strategy {
+	case LargestFirst:
+		inputs = selectLargestFirst(candidates, values, needed)
+	case SmallestFirst:
+		inputs = selectSmallestFirst(candidates, values, needed)
+	case BranchAndBound:
+		inputs = selectBranchAndBound(candidates, values, needed)
+		if inputs == nil {
+			inputs = selectLargestFirst(candidates, values, needed)
+		}
+	case Randomized:
+		var err error
+		inputs, err = selectRandomized(candidates, values, needed, rng)
+		if err != nil {
+			return nil, err
+		}
+		if inputs == nil {
+			inputs = selectLargestFirst(candidates, values, needed)
+		}
+	default:
+		return nil, fmt.Errorf("Invalid selection strategy %d", strategy)
+	}
+
+	selection := &Selection{
+		Inputs: inputs,
+		Target: target,
+		Fee:    fee,
+	}
+	for _, utxo := range inputs {
+		selection.Total += values[utxo]
+	}
+	return selection, nil
+}
+
+func unspentValue(utxo *UnspentTxOut) (uint64, error) {
+	if utxo == nil || utxo.TxOut == nil {
+		return 0, errors.New("Invalid unspent tx out")
+	}
+	value, err := strconv.ParseUint(utxo.Value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid unspent tx out value %s", utxo.Value)
+	}
+	return value, nil
+}
+
+// candidates are sorted by value descending.
+func selectLargestFirst(candidates []*UnspentTxOut, values map[*UnspentTxOut]uint64, needed uint64) []*UnspentTxOut {
+	var sum uint64
+	for i, utxo := range candidates {
+		sum += values[utxo]
+		if sum >= needed {
+			return append([]*UnspentTxOut{}, candidates[:i+1]...)
+		}
+	}
+	return nil
+}
+
+// Walks the candidates from the smallest with a window of at most
+// MAX_INPUTS outputs, dropping the smallest when the window is full.
+func selectSmallestFirst(candidates []*UnspentTxOut, values map[*UnspentTxOut]uint64, needed uint64) []*UnspentTxOut {
+	var sum uint64
+	start := len(candidates) - 1
+	for end := len(candidates) - 1; end >= 0; end-- {
+		sum += values[candidates[end]]
+		if start-end+1 > MAX_INPUTS {
+			sum -= values[candidates[start]]
+			start--
+		}
+		if sum >= needed {
+			inputs := make([]*UnspentTxOut, 0, start-end+1)
+			for i := start; i >= end; i-- {
+				inputs = append(inputs, candidates[i])
+			}
+			return inputs
+		}
+	}
+	return nil
+}
+
+// Depth first search over the candidates sorted by value descending for a
+// subset worth exactly needed, pruning branches which overshoot or can not
+// reach it anymore.
+func selectBranchAndBound(candidates []*UnspentTxOut, values map[*UnspentTxOut]uint64, needed uint64) []*UnspentTxOut {
+	remaining := make([]uint64, len(candidates)+1)
+	for i := len(candidates) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + values[candidates[i]]
+	}
+
+	tries := 0
+	var selected []int
+	var search func(index int, sum uint64) bool
+	search = func(index int, sum uint64) bool {
+		if sum == needed {
+			return true
+		}
+		tries++
+		if tries > branchAndBoundMaxTries || index >= len(candidates) || len(selected) >= MAX_INPUTS {
+			return false
+		}
+		if sum+remaining[index] < needed {
+			return false
+		}
+		value := values[candidates[index]]
+		if sum+value <= needed {
+			selected = append(selected, index)
+			if search(index+1, sum+value) {
+				return true
+			}
+			selected = selected[:len(selected)-1]
+		}
+		return search(index+1, sum)
+	}
+	if !search(0, 0) {
+		return nil
+	}
+
+	inputs := make([]*UnspentTxOut, len(selected))
+	for i, index := range selected {
+		inputs[i] = candidates[index]
+	}
+	return inputs
+}
+
+func selectRandomized(candidates []*UnspentTxOut, values map[*UnspentTxOut]uint64, needed uint64, rng io.Reader) ([]*UnspentTxOut, error) {
+	shuffled := append([]*UnspentTxOut{}, candidates...)
+	for i := len(shuffled) - 1; i > 0; i-- {
+		j, err := rand.Int(rng, big.NewInt(int64(i+1)))
+		if err != nil {
+			return nil, err
+		}
+		shuffled[i], shuffled[j.Int64()] = shuffled[j.Int64()], shuffled[i]
+	}
+
+	var sum uint64
+	for i := 0; i < len(shuffled) && i < MAX_INPUTS; i++ {
+		sum += values[shuffled[i]]
+		if sum >= needed {
+			return shuffled[:i+1], nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestUnspentTxOuts(values ...uint64) []*UnspentTxOut {
+	utxos := make([]*UnspentTxOut, len(values))
+	for i, value := range values {
+		utxos[i] = &UnspentTxOut{
+			TxOut: &TxOut{PublicKey: testPublicKey(i)},
+			Value: strconv.FormatUint(value, 10),
+		}
+	}
+	return utxos
+}
+
+func testPublicKey(i int) CompressedRistretto {
+	var key CompressedRistretto
+	binary.BigEndian.PutUint32(key[28:], crc32.ChecksumIEEE([]byte(strconv.Itoa(i))))
+	return key
+}
+
+func selectedValues(selection *Selection) []uint64 {
+	values := make([]uint64, len(selection.Inputs))
+	for i, utxo := range selection.Inputs {
+		values[i], _ = strconv.ParseUint(utxo.Value, 10, 64)
+	}
+	return values
+}
+
+func TestSelectInputs(t *testing.T) {
+	assert := assert.New(t)
+
+	utxos := newTestUnspentTxOuts(5, 1, 8, 3, 2, 0)
+
+	selection, err := SelectInputs(utxos, 6, 1, LargestFirst)
+	assert.Nil(err)
+	assert.Equal([]uint64{8}, selectedValues(selection))
+	assert.Equal(uint64(8), selection.Total)
+	assert.Equal(uint64(1), selection.Change())
+
+	selection, err = SelectInputs(utxos, 5, 1, SmallestFirst)
+	assert.Nil(err)
+	assert.Equal([]uint64{1, 2, 3}, selectedValues(selection))
+	assert.Equal(uint64(0), selection.Change())
+
+	selection, err = SelectInputs(utxos, 9, 1, BranchAndBound)
+	assert.Nil(err)
+	assert.Equal([]uint64{8, 2}, selectedValues(selection))
+	assert.Equal(uint64(0), selection.Change())
+
+	// no exact match, falls back to largest first
+	selection, err = SelectInputs(newTestUnspentTxOuts(10, 20), 14, 1, BranchAndBound)
+	assert.Nil(err)
+	assert.Equal([]uint64{20}, selectedValues(selection))
+
+	for i := 0; i < 20; i++ {
+		selection, err = SelectInputs(utxos, 12, 1, Randomized)
+		assert.Nil(err)
+		assert.True(selection.Total >= 13)
+		assert.True(len(selection.Inputs) <= MAX_INPUTS)
+	}
+
+	selection, err = SelectInputsWithRNG(utxos, 6, 1, Randomized, &testRNG{seed: []byte("seed")})
+	assert.Nil(err)
+	other, err := SelectInputsWithRNG(utxos, 6, 1, Randomized, &testRNG{seed: []byte("seed")})
+	assert.Nil(err)
+	assert.Equal(selection, other)
+
+	_, err = SelectInputs(utxos, 19, 1, LargestFirst)
+	assert.True(errors.Is(err, ErrInsufficientFunds))
+	_, err = SelectInputs(nil, 0, 1, SmallestFirst)
+	assert.True(errors.Is(err, ErrInsufficientFunds))
+	_, err = SelectInputs(utxos, 1, 1, SelectionStrategy(10))
+	assert.NotNil(err)
+	_, err = SelectInputs(newTestUnspentTxOuts(1, 2), ^uint64(0), 1, LargestFirst)
+	assert.NotNil(err)
+	invalid := newTestUnspentTxOuts(1)
+	invalid[0].Value = "-1"
+	_, err = SelectInputs(invalid, 1, 1, LargestFirst)
+	assert.NotNil(err)
+
+	fragmented := make([]uint64, MAX_INPUTS*2)
+	for i := range fragmented {
+		fragmented[i] = 10
+	}
+	for _, strategy := range []SelectionStrategy{LargestFirst, SmallestFirst, BranchAndBound, Randomized} {
+		_, err = SelectInputs(newTestUnspentTxOuts(fragmented...), 10*MAX_INPUTS, 1, strategy)
+		assert.True(errors.Is(err, ErrTooFragmented))
+
+		selection, err = SelectInputs(newTestUnspentTxOuts(fragmented...), 10*MAX_INPUTS-1, 1, strategy)
+		assert.Nil(err)
+		assert.Len(selection.Inputs, MAX_INPUTS)
+	}
+
+	// the smallest window of MAX_INPUTS outputs which covers the target
+	values := []uint64{100}
+	for i := 0; i < MAX_INPUTS+4; i++ {
+		values = append(values, uint64(i+1))
+	}
+	selection, err = SelectInputs(newTestUnspentTxOuts(values...), 150, 0, SmallestFirst)
+	assert.Nil(err)
+	assert.Len(selection.Inputs, MAX_INPUTS)
+	assert.True(selection.Total >= 150)
+	assert.Equal(uint64(2), selectedValues(selection)[0])
+}
+
+func TestSelectionInputCredentials(t *testing.T) {
+	assert := assert.New(t)
+
+	acc, address := newTestAccount()
+	var utxos []*UnspentTxOut
+	rings := make(map[string][]*TxOutWithProof)
+	proofSet := make(map[string]*TxOutMembershipProof)
+	for _, value := range []uint64{3 * MILLIMOB_TO_PICOMOB, 2 * MILLIMOB_TO_PICOMOB} {
+		input := newTestInputCredential(acc, address, value)
+		real := input.Ring[input.RealIndex]
+		utxos = append(utxos, &UnspentTxOut{TxOut: real, Value: strconv.FormatUint(value, 10)})
+		proofSet[real.PublicKey.String()] = input.MembershipProofs[input.RealIndex]
+		for i := range input.Ring {
+			rings[real.PublicKey.String()] = append(rings[real.PublicKey.String()], &TxOutWithProof{TxOut: input.Ring[i], Proof: input.MembershipProofs[i]})
+		}
+	}
+
+	selection, err := SelectInputs(utxos, 4*MILLIMOB_TO_PICOMOB, MINIMUM_FEE, LargestFirst)
+	assert.Nil(err)
+	assert.Len(selection.Inputs, 2)
+
+	private := hex.EncodeToString(acc.ViewPrivateKey.Bytes()) + hex.EncodeToString(acc.SpendPrivateKey.Bytes())
+	inputs, err := selection.InputCredentials(private, proofSet, rings)
+	assert.Nil(err)
+	assert.Len(inputs, 2)
+	_, err = selection.InputCredentials(private[:64], proofSet, rings)
+	assert.NotNil(err)
+
+	tb := &TransactionBuilder{InputCredentials: inputs, Fee: MINIMUM_FEE, TombstoneBlock: 150}
+	for i, value := range []uint64{4 * MILLIMOB_TO_PICOMOB, selection.Change()} {
+		_, recipient := newTestAccount()
+		output, _, err := CreateOutput(value, recipient, i)
+		assert.Nil(err)
+		tb.OutputsAndSharedSecrets = append(tb.OutputsAndSharedSecrets, output)
+	}
+	tx, err := tb.Build()
+	assert.Nil(err)
+	assert.Nil(ValidateTx(tx, 100))
+}
@@ -0,0 +1,416 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// https://github.com/mobilecoinfoundation/mobilecoin/blob/master/consensus/api/proto/consensus_common.proto
+type ProposeTxResult int32
+
+const (
+	ProposeTxOk                              ProposeTxResult = 0
+	ProposeTxInputsProofsLengthMismatch      ProposeTxResult = 10
+	ProposeTxNoInputs                        ProposeTxResult = 11
+	ProposeTxTooManyInputs                   ProposeTxResult = 12
+	ProposeTxInsufficientInputSignatures     ProposeTxResult = 13
+	ProposeTxInvalidInputSignature           ProposeTxResult = 14
+	ProposeTxInvalidTransactionSignature     ProposeTxResult = 15
+	ProposeTxInvalidRangeProof               ProposeTxResult = 16
+	ProposeTxInsufficientRingSize            ProposeTxResult = 17
+	ProposeTxTombstoneBlockExceeded          ProposeTxResult = 18
+	ProposeTxTombstoneBlockTooFar            ProposeTxResult = 19
+	ProposeTxNoOutputs                       ProposeTxResult = 20
+	ProposeTxTooManyOutputs                  ProposeTxResult = 21
+	ProposeTxExcessiveRingSize               ProposeTxResult = 22
+	ProposeTxDuplicateRingElements           ProposeTxResult = 23
+	ProposeTxUnsortedRingElements            ProposeTxResult = 24
+	ProposeTxUnequalRingSizes                ProposeTxResult = 25
+	ProposeTxUnsortedKeyImages               ProposeTxResult = 26
+	ProposeTxContainsSpentKeyImage           ProposeTxResult = 27
+	ProposeTxDuplicateKeyImages              ProposeTxResult = 28
+	ProposeTxDuplicateOutputPublicKey        ProposeTxResult = 29
+	ProposeTxContainsExistingOutputPublicKey ProposeTxResult = 30
+	ProposeTxMissingTxOutMembershipProof     ProposeTxResult = 31
+	ProposeTxInvalidTxOutMembershipProof     ProposeTxResult = 32
+	ProposeTxInvalidRistrettoPublicKey       ProposeTxResult = 33
+	ProposeTxInvalidLedgerContext            ProposeTxResult = 34
+	ProposeTxLedger                          ProposeTxResult = 35
+	ProposeTxMembershipProofValidationError  ProposeTxResult = 36
+	ProposeTxTxFeeError                      ProposeTxResult = 37
+	ProposeTxKeyError                        ProposeTxResult = 38
+	ProposeTxUnsortedInputs                  ProposeTxResult = 39
+)
+
+var proposeTxResultNames = map[ProposeTxResult]string{
+	ProposeTxOk:                              "Ok",
+	ProposeTxInputsProofsLengthMismatch:      "InputsProofsLengthMismatch",
+	ProposeTxNoInputs:                        "NoInputs",
+	ProposeTxTooManyInputs:                   "TooManyInputs",
+	ProposeTxInsufficientInputSignatures:     "InsufficientInputSignatures",
+	ProposeTxInvalidInputSignature:           "InvalidInputSignature",
+	ProposeTxInvalidTransactionSignature:     "InvalidTransactionSignature",
+	ProposeTxInvalidRangeProof:               "InvalidRangeProof",
+	ProposeTxInsufficientRingSize:            "InsufficientRingSize",
+	ProposeTxTombstoneBlockExceeded:          "TombstoneBlockExceeded",
+	ProposeTxTombstoneBlockTooFar:            "TombstoneBlockTooFar",
+	ProposeTxNoOutputs:                       "NoOutputs",
+	ProposeTxTooManyOutputs:                  "TooManyOutputs",
+	ProposeTxExcessiveRingSize:               "ExcessiveRingSize",
+	ProposeTxDuplicateRingElements:           "DuplicateRingElements",
+	ProposeTxUnsortedRingElements:            "UnsortedRingElements",
+	ProposeTxUnequalRingSizes:                "UnequalRingSizes",
+	ProposeTxUnsortedKeyImages:               "UnsortedKeyImages",
+	ProposeTxContainsSpentKeyImage:           "ContainsSpentKeyImage",
+	ProposeTxDuplicateKeyImages:              "DuplicateKeyImages",
+	ProposeTxDuplicateOutputPublicKey:        "DuplicateOutputPublicKey",
+	ProposeTxContainsExistingOutputPublicKey: "ContainsExistingOutputPublicKey",
+	ProposeTxMissingTxOutMembershipProof:     "MissingTxOutMembershipProof",
+	ProposeTxInvalidTxOutMembershipProof:     "InvalidTxOutMembershipProof",
+	ProposeTxInvalidRistrettoPublicKey:       "InvalidRistrettoPublicKey",
+	ProposeTxInvalidLedgerContext:            "InvalidLedgerContext",
+	ProposeTxLedger:                          "Ledger",
+	ProposeTxMembershipProofValidationError:  "MembershipProofValidationError",
+	ProposeTxTxFeeError:                      "TxFeeError",
+	ProposeTxKeyError:                        "KeyError",
+	ProposeTxUnsortedInputs:                  "UnsortedInputs",
+}
+
+// proposeTxResultRules maps the results to the rules of ValidateTx which
+// reject the same transactions.
+var proposeTxResultRules = map[ProposeTxResult]ValidationRule{
+	ProposeTxNoInputs:                    NoInputs,
+	ProposeTxTooManyInputs:               TooManyInputs,
+	ProposeTxInvalidTransactionSignature: InvalidTransactionSignature,
+	ProposeTxInvalidRangeProof:           InvalidRangeProof,
+	ProposeTxInsufficientRingSize:        InsufficientRingSize,
+	ProposeTxTombstoneBlockExceeded:      TombstoneBlockExceeded,
+	ProposeTxTombstoneBlockTooFar:        TombstoneBlockTooFar,
+	ProposeTxNoOutputs:                   NoOutputs,
+	ProposeTxTooManyOutputs:              TooManyOutputs,
+	ProposeTxExcessiveRingSize:           ExcessiveRingSize,
+	ProposeTxDuplicateRingElements:       DuplicateRingElements,
+	ProposeTxUnsortedRingElements:        UnsortedRingElements,
+	ProposeTxDuplicateKeyImages:          DuplicateKeyImages,
+	ProposeTxDuplicateOutputPublicKey:    DuplicateOutputPublicKey,
+	ProposeTxMissingTxOutMembershipProof: MissingTxOutMembershipProof,
+	ProposeTxInvalidTxOutMembershipProof: InvalidTxOutMembershipProof,
+	ProposeTxTxFeeError:                  TxFeeError,
+	ProposeTxUnsortedInputs:              UnsortedInputs,
+}
+
+func (r ProposeTxResult) String() string {
+	if name, ok := proposeTxResultNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("ProposeTxResult(%d)", int(r))
+}
+
+// ProposeTxError is a transaction rejected by the consensus network, use
+// errors.Is(err, &ProposeTxError{Result: ProposeTxContainsSpentKeyImage})
+// to match a result. The results checked by ValidateTx also match their
+// ValidationError, e.g. &ValidationError{Rule: TombstoneBlockExceeded}.
+type ProposeTxError struct {
+	Result     ProposeTxResult
+	BlockCount uint64
+}
+
+func (e *ProposeTxError) Error() string {
+	return fmt.Sprintf("%s at block count %d", e.Result, e.BlockCount)
+}
+
+func (e *ProposeTxError) Is(target error) bool {
+	switch t := target.(type) {
+	case *ProposeTxError:
+		return t.Result == e.Result
+	case *ValidationError:
+		rule, ok := proposeTxResultRules[e.Result]
+		return ok && t.Rule == rule
+	}
+	return false
+}
+
+// AttestedConnection is the client side of the key exchange with an
+// enclave, once attested it encrypts the requests to the enclave and
+// decrypts its responses.
+type AttestedConnection interface {
+	AuthRequest(responderID string) ([]byte, error)
+	ProcessAuthResponse(response []byte) error
+	Binding() ([]byte, error)
+	Encrypt(aad, plaintext []byte) ([]byte, error)
+	Decrypt(aad, ciphertext []byte) ([]byte, error)
+	Close() error
+}
+
+// ConsensusClient proposes transactions to the attested client API of a
+// consensus node. The enclave is attested on the first request, and again
+// when the node has dropped the session.
+type ConsensusClient struct {
+	conn        *grpc.ClientConn
+	responderID string
+	newAttested func() (AttestedConnection, error)
+	mutex       sync.Mutex
+	attested    AttestedConnection
+}
+
+// NewConsensusClient makes a client of the node responderID, host:port,
+// newAttested is called for every new attestation.
+func NewConsensusClient(conn *grpc.ClientConn, responderID string, newAttested func() (AttestedConnection, error)) *ConsensusClient {
+	return &ConsensusClient{
+		conn:        conn,
+		responderID: responderID,
+		newAttested: newAttested,
+	}
+}
+
+// DialConsensusClient connects to a consensus node uri, e.g.
+// mc://node1.prod.mobilecoinww.com
+func DialConsensusClient(address string, newAttested func() (AttestedConnection, error)) (*ConsensusClient, error) {
+	uri, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+	host := uri.Host
+	if uri.Port() == "" {
+		host = net.JoinHostPort(uri.Hostname(), "443")
+	}
+
+	// Use system RootCAs
+	creds := credentials.NewTLS(&tls.Config{})
+	conn, err := grpc.Dial(host, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	return NewConsensusClient(conn, host, newAttested), nil
+}
+
+func (c *ConsensusClient) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.reset()
+	return c.conn.Close()
+}
+
+// Attest runs the key exchange with the enclave if there is no session yet.
+func (c *ConsensusClient) Attest(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_, err := c.attest(ctx)
+	return err
+}
+
+// ProposeTx submits tx and returns the block count of the node, a rejected
+// transaction is a *ProposeTxError.
+func (c *ConsensusClient) ProposeTx(ctx context.Context, tx *Tx) (uint64, error) {
+	message, err := TxToProto(tx)
+	if err != nil {
+		return 0, err
+	}
+	data, err := proto.Marshal(message)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	response, err := c.propose(ctx, data)
+	if status.Code(err) == codes.Unauthenticated {
+		c.reset()
+		response, err = c.propose(ctx, data)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if response.Result != ProposeTxOk {
+		return response.BlockCount, &ProposeTxError{Result: response.Result, BlockCount: response.BlockCount}
+	}
+	return response.BlockCount, nil
+}
+
+func (c *ConsensusClient) propose(ctx context.Context, data []byte) (*proposeTxResponse, error) {
+	attested, err := c.attest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	binding, err := attested.Binding()
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := attested.Encrypt(nil, data)
+	if err != nil {
+		return nil, err
+	}
+	request := &attestMessage{ChannelID: binding, Data: ciphertext}
+	response := &proposeTxResponse{}
+	err = c.conn.Invoke(ctx, "/consensus_client.ConsensusClientAPI/ClientTxPropose", request, response, grpc.ForceCodec(attestCodec{}))
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *ConsensusClient) attest(ctx context.Context) (AttestedConnection, error) {
+	if c.attested != nil {
+		return c.attested, nil
+	}
+	attested, err := c.newAttested()
+	if err != nil {
+		return nil, err
+	}
+	request, err := attested.AuthRequest(c.responderID)
+	if err != nil {
+		attested.Close()
+		return nil, err
+	}
+	response := &authMessage{}
+	err = c.conn.Invoke(ctx, "/attest.AttestedApi/Auth", &authMessage{Data: request}, response, grpc.ForceCodec(attestCodec{}))
+	if err != nil {
+		attested.Close()
+		return nil, err
+	}
+	err = attested.ProcessAuthResponse(response.Data)
+	if err != nil {
+		attested.Close()
+		return nil, err
+	}
+	c.attested = attested
+	return attested, nil
+}
+
+func (c *ConsensusClient) reset() {
+	if c.attested != nil {
+		c.attested.Close()
+		c.attested = nil
+	}
+}
+
+// authMessage is attest.AuthMessage.
+type authMessage struct {
+	Data []byte
+}
+
+// attestMessage is attest.Message, Data is encrypted for the channel.
+type attestMessage struct {
+	AAD       []byte
+	ChannelID []byte
+	Data      []byte
+}
+
+// proposeTxResponse is consensus_common.ProposeTxResponse.
+type proposeTxResponse struct {
+	Result     ProposeTxResult
+	BlockCount uint64
+}
+
+// attestCodec encodes the attest messages and the ProposeTxResponse, there
+// are no generated types for the attest and consensus protos.
+type attestCodec struct{}
+
+func (attestCodec) Name() string {
+	return "proto"
+}
+
+func (attestCodec) Marshal(v interface{}) ([]byte, error) {
+	var b []byte
+	switch m := v.(type) {
+	case *authMessage:
+		b = appendBytesField(b, 1, m.Data)
+	case *attestMessage:
+		b = appendBytesField(b, 1, m.AAD)
+		b = appendBytesField(b, 2, m.ChannelID)
+		b = appendBytesField(b, 3, m.Data)
+	case *proposeTxResponse:
+		b = appendVarintField(b, 1, uint64(m.Result))
+		b = appendVarintField(b, 2, m.BlockCount)
+	default:
+		return nil, fmt.Errorf("Invalid message type %T", v)
+	}
+	return b, nil
+}
+
+func (attestCodec) Unmarshal(data []byte, v interface{}) error {
+	switch v.(type) {
+	case *authMessage, *attestMessage, *proposeTxResponse:
+	default:
+		return fmt.Errorf("Invalid message type %T", v)
+	}
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		switch typ {
+		case protowire.BytesType:
+			value, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+			value = append([]byte{}, value...)
+			switch m := v.(type) {
+			case *authMessage:
+				if num == 1 {
+					m.Data = value
+				}
+			case *attestMessage:
+				switch num {
+				case 1:
+					m.AAD = value
+				case 2:
+					m.ChannelID = value
+				case 3:
+					m.Data = value
+				}
+			}
+		case protowire.VarintType:
+			value, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+			if m, ok := v.(*proposeTxResponse); ok {
+				switch num {
+				case 1:
+					m.Result = ProposeTxResult(int32(value))
+				case 2:
+					m.BlockCount = value
+				}
+			}
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+		}
+	}
+	return nil
+}
+
+func appendBytesField(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+func appendVarintField(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/jadeydi/mobilecoin-account/block"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+// testAttestedConnection stands in for the enclave key exchange, the
+// binding is the hash of the auth request and the cipher a xor with it.
+type testAttestedConnection struct {
+	request []byte
+	binding []byte
+	closed  bool
+}
+
+func (a *testAttestedConnection) AuthRequest(responderID string) ([]byte, error) {
+	a.request = []byte(responderID)
+	return a.request, nil
+}
+
+func (a *testAttestedConnection) ProcessAuthResponse(response []byte) error {
+	binding := sha256.Sum256(a.request)
+	if !bytes.Equal(binding[:], response) {
+		return errors.New("Invalid auth response")
+	}
+	a.binding = response
+	return nil
+}
+
+func (a *testAttestedConnection) Binding() ([]byte, error) {
+	return a.binding, nil
+}
+
+func (a *testAttestedConnection) Encrypt(aad, plaintext []byte) ([]byte, error) {
+	return testXor(a.binding, plaintext), nil
+}
+
+func (a *testAttestedConnection) Decrypt(aad, ciphertext []byte) ([]byte, error) {
+	return testXor(a.binding, ciphertext), nil
+}
+
+func (a *testAttestedConnection) Close() error {
+	a.closed = true
+	return nil
+}
+
+func testXor(key, data []byte) []byte {
+	out := make([]byte, len(data))
+	for i := range data {
+		out[i] = data[i] ^ key[i%len(key)]
+	}
+	return out
+}
+
+type testConsensusServer struct {
+	result     ProposeTxResult
+	blockCount uint64
+	sessions   map[string]bool
+	auths      int
+	txs        []*Tx
+}
+
+func (s *testConsensusServer) serve(t *testing.T, attested *[]*testAttestedConnection) *ConsensusClient {
+	s.sessions = make(map[string]bool)
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(grpc.ForceServerCodec(attestCodec{}))
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "attest.AttestedApi",
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Auth",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+				request := &authMessage{}
+				if err := dec(request); err != nil {
+					return nil, err
+				}
+				s.auths++
+				binding := sha256.Sum256(request.Data)
+				s.sessions[string(binding[:])] = true
+				return &authMessage{Data: binding[:]}, nil
+			},
+		}},
+	}, s)
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "consensus_client.ConsensusClientAPI",
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "ClientTxPropose",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+				request := &attestMessage{}
+				if err := dec(request); err != nil {
+					return nil, err
+				}
+				if !s.sessions[string(request.ChannelID)] {
+					return nil, status.Error(codes.Unauthenticated, "unknown channel")
+				}
+				message := &block.Tx{}
+				if err := proto.Unmarshal(testXor(request.ChannelID, request.Data), message); err != nil {
+					return nil, err
+				}
+				tx, err := TxFromProto(message)
+				if err != nil {
+					return nil, err
+				}
+				s.txs = append(s.txs, tx)
+				return &proposeTxResponse{Result: s.result, BlockCount: s.blockCount}, nil
+			},
+		}},
+	}, s)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return listener.Dial()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewConsensusClient(conn, "node1.test.mobilecoin.com:443", func() (AttestedConnection, error) {
+		a := &testAttestedConnection{}
+		*attested = append(*attested, a)
+		return a, nil
+	})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestConsensusClient(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	tx := newTestTx([]uint64{3 * MILLIMOB_TO_PICOMOB}, []uint64{3*MILLIMOB_TO_PICOMOB - MINIMUM_FEE}, MINIMUM_FEE, 150)
+	server := &testConsensusServer{blockCount: 120}
+	var attested []*testAttestedConnection
+	client := server.serve(t, &attested)
+
+	blockCount, err := client.ProposeTx(ctx, tx)
+	assert.Nil(err)
+	assert.Equal(uint64(120), blockCount)
+	assert.Len(server.txs, 1)
+	hash, err := tx.Hash()
+	assert.Nil(err)
+	proposed, err := server.txs[0].Hash()
+	assert.Nil(err)
+	assert.Equal(hash, proposed)
+	assert.Equal(1, server.auths)
+
+	server.result = ProposeTxContainsSpentKeyImage
+	blockCount, err = client.ProposeTx(ctx, tx)
+	assert.Equal(uint64(120), blockCount)
+	assert.True(errors.Is(err, &ProposeTxError{Result: ProposeTxContainsSpentKeyImage}))
+	assert.False(errors.Is(err, &ProposeTxError{Result: ProposeTxDuplicateKeyImages}))
+	assert.False(errors.Is(err, &ValidationError{Rule: DuplicateKeyImages}))
+	assert.Equal("ContainsSpentKeyImage at block count 120", err.Error())
+
+	server.result = ProposeTxTombstoneBlockExceeded
+	server.blockCount = 151
+	_, err = client.ProposeTx(ctx, tx)
+	assert.True(errors.Is(err, &ProposeTxError{Result: ProposeTxTombstoneBlockExceeded}))
+	assert.True(errors.Is(err, &ValidationError{Rule: TombstoneBlockExceeded}))
+	var proposeErr *ProposeTxError
+	assert.True(errors.As(err, &proposeErr))
+	assert.Equal(uint64(151), proposeErr.BlockCount)
+	assert.Equal(1, server.auths)
+	for result, rule := range proposeTxResultRules {
+		assert.Equal(result.String(), rule.String())
+		assert.True(errors.Is(&ProposeTxError{Result: result}, &ValidationError{Rule: rule}))
+	}
+	assert.False(errors.Is(&ProposeTxError{Result: ProposeTxResult(99)}, &ValidationError{Rule: ValidationRule(99)}))
+
+	// the node lost the session, the client attests again
+	server.sessions = make(map[string]bool)
+	server.result = ProposeTxOk
+	_, err = client.ProposeTx(ctx, tx)
+	assert.Nil(err)
+	assert.Equal(2, server.auths)
+	assert.Len(attested, 2)
+	assert.True(attested[0].closed)
+	assert.False(attested[1].closed)
+	assert.Len(server.txs, 4)
+
+	_, err = client.ProposeTx(ctx, &Tx{})
+	assert.NotNil(err)
+	assert.Len(server.txs, 4)
+	assert.Equal("ProposeTxResult(99)", ProposeTxResult(99).String())
+}
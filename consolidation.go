@@ -0,0 +1,179 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	account "github.com/jadeydi/mobilecoin-account"
+)
+
+type ConsolidationOptions struct {
+	// Fee is paid by every consolidation transaction, when it is 0 each
+	// transaction pays the estimate of FeeEstimator.
+	Fee            uint64
+	FeeEstimator   FeeEstimator
+	TombstoneBlock uint64
+	// Address is the subaddress receiving the merged outputs.
+	Address *account.PublicAddress
+	// MaxTransactions bounds the plan, 0 for no bound.
+	MaxTransactions int
+	// DryRun only plans, no transaction is built.
+	DryRun          bool
+	InputCredential func(utxo *UnspentTxOut) (*InputCredential, error)
+	Rand            io.Reader
+}
+
+type ConsolidationTransaction struct {
+	Inputs []*UnspentTxOut
+	// Value is the sum of the inputs, the output is worth Value - Fee.
+	Value    uint64
+	Fee      uint64
+	Output   uint64
+	Proposal *TxProposal
+}
+
+// ConsolidationPlan reports the fee cost against the benefit, which is how
+// much more a single transaction can spend after the consolidation.
+type ConsolidationPlan struct {
+	Transactions []*ConsolidationTransaction
+	// Skipped outputs are worth less than their share of the fee of a
+	// transaction spending MAX_INPUTS.
+	Skipped []*UnspentTxOut
+	Fee     uint64
+
+	OutputsBefore  int
+	OutputsAfter   int
+	MaxSpendBefore uint64
+	MaxSpendAfter  uint64
+}
+
+// Consolidate merges the outputs, smallest first and MAX_INPUTS at a time,
+// into one output each at opts.Address.
+func Consolidate(utxos []*UnspentTxOut, opts *ConsolidationOptions) (*ConsolidationPlan, error) {
+	if opts == nil || opts.Address == nil {
+		return nil, errors.New("Invalid consolidation options, address required")
+	}
+	if opts.Fee == 0 && opts.FeeEstimator == nil {
+		return nil, errors.New("Invalid consolidation options, fee required")
+	}
+	// the fee of a transaction spending MAX_INPUTS, with a change output
+	fullFee, err := opts.fee(MAX_INPUTS, 2)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.DryRun && opts.InputCredential == nil {
+		return nil, errors.New("Invalid consolidation options, input credential required")
+	}
+
+	plan := &ConsolidationPlan{OutputsBefore: len(utxos)}
+	var candidates []*UnspentTxOut
+	values := make(map[*UnspentTxOut]uint64, len(utxos))
+	var before []uint64
+	for _, utxo := range utxos {
+		value, err := unspentValue(utxo)
+		if err != nil {
+			return nil, err
+		}
+		values[utxo] = value
+		before = append(before, value)
+		if value <= fullFee/MAX_INPUTS {
+			plan.Skipped = append(plan.Skipped, utxo)
+			continue
+		}
+		candidates = append(candidates, utxo)
+	}
+	plan.MaxSpendBefore = maxSpend(before, fullFee)
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return values[candidates[i]] < values[candidates[j]]
+	})
+
+	consolidated := make(map[*UnspentTxOut]bool)
+	var after []uint64
+	for start := 0; start+1 < len(candidates); start += MAX_INPUTS {
+		if opts.MaxTransactions > 0 && len(plan.Transactions) >= opts.MaxTransactions {
+			break
+		}
+		end := start + MAX_INPUTS
+		if end > len(candidates) {
+			end = len(candidates)
+		}
+		fee, err := opts.fee(end-start, 1)
+		if err != nil {
+			return nil, err
+		}
+		tx := &ConsolidationTransaction{
+			Inputs: candidates[start:end],
+			Fee:    fee,
+		}
+		for _, utxo := range tx.Inputs {
+			tx.Value += values[utxo]
+		}
+		if tx.Value <= tx.Fee {
+			break
+		}
+		tx.Output = tx.Value - tx.Fee
+		for _, utxo := range tx.Inputs {
+			consolidated[utxo] = true
+		}
+		after = append(after, tx.Output)
+		plan.Transactions = append(plan.Transactions, tx)
+		plan.Fee += tx.Fee
+	}
+	for _, utxo := range utxos {
+		if !consolidated[utxo] {
+			after = append(after, values[utxo])
+		}
+	}
+	plan.OutputsAfter = len(after)
+	plan.MaxSpendAfter = maxSpend(after, fullFee)
+
+	if opts.DryRun {
+		return plan, nil
+	}
+	for i, tx := range plan.Transactions {
+		tb := NewTransactionBuilder(&TransactionBuilderOptions{
+			Fee:            tx.Fee,
+			TombstoneBlock: opts.TombstoneBlock,
+			Rand:           opts.Rand,
+		})
+		for _, utxo := range tx.Inputs {
+			input, err := opts.InputCredential(utxo)
+			if err != nil {
+				return nil, err
+			}
+			tb.AddInput(input)
+		}
+		proposal, err := tb.AddOutput(tx.Output, opts.Address).BuildTxProposal()
+		if err != nil {
+			return nil, fmt.Errorf("Consolidation transaction %d %w", i, err)
+		}
+		tx.Proposal = proposal
+	}
+	return plan, nil
+}
+
+// maxSpend is the most a single transaction can send from values.
+func maxSpend(values []uint64, fee uint64) uint64 {
+	sorted := append([]uint64{}, values...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] > sorted[j]
+	})
+	var sum uint64
+	for i := 0; i < len(sorted) && i < MAX_INPUTS; i++ {
+		sum += sorted[i]
+	}
+	if sum <= fee {
+		return 0
+	}
+	return sum - fee
+}
+
+func (opts *ConsolidationOptions) fee(numInputs, numOutputs int) (uint64, error) {
+	if opts.Fee > 0 {
+		return opts.Fee, nil
+	}
+	return opts.FeeEstimator.EstimateFee(numInputs, numOutputs)
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsolidate(t *testing.T) {
+	assert := assert.New(t)
+
+	acc, address := newTestAccount()
+	credentials := make(map[CompressedRistretto]*InputCredential)
+	var utxos []*UnspentTxOut
+	for i := 0; i < 3; i++ {
+		input := newTestInputCredential(acc, address, MILLIMOB_TO_PICOMOB)
+		real := input.Ring[input.RealIndex]
+		credentials[real.PublicKey] = input
+		utxos = append(utxos, &UnspentTxOut{TxOut: real, Value: strconv.FormatUint(MILLIMOB_TO_PICOMOB, 10)})
+	}
+
+	opts := &ConsolidationOptions{
+		Fee:            MINIMUM_FEE,
+		TombstoneBlock: 150,
+		Address:        address,
+		InputCredential: func(utxo *UnspentTxOut) (*InputCredential, error) {
+			return credentials[utxo.TxOut.PublicKey], nil
+		},
+	}
+	plan, err := Consolidate(utxos, opts)
+	assert.Nil(err)
+	assert.Len(plan.Transactions, 1)
+	tx := plan.Transactions[0]
+	assert.Len(tx.Inputs, 3)
+	assert.Equal(uint64(3*MILLIMOB_TO_PICOMOB-MINIMUM_FEE), tx.Output)
+	assert.Nil(ValidateTx(tx.Proposal.Tx, 100))
+	assert.Len(tx.Proposal.Tx.Prefix.Outputs, 1)
+	value, _ := GetValueWithBlinding(tx.Proposal.Tx.Prefix.Outputs[0], acc.ViewPrivateKey)
+	assert.Equal(tx.Output, value)
+
+	_, err = Consolidate(utxos, &ConsolidationOptions{Fee: MINIMUM_FEE, Address: address})
+	assert.NotNil(err)
+	_, err = Consolidate(utxos, &ConsolidationOptions{Address: address, DryRun: true})
+	assert.NotNil(err)
+}
+
+func TestConsolidateDryRun(t *testing.T) {
+	assert := assert.New(t)
+
+	_, address := newTestAccount()
+	values := []uint64{1, 2}
+	for i := 0; i < 40; i++ {
+		values = append(values, 100)
+	}
+	utxos := newTestUnspentTxOuts(values...)
+	opts := &ConsolidationOptions{Fee: 32, Address: address, DryRun: true}
+
+	plan, err := Consolidate(utxos, opts)
+	assert.Nil(err)
+	// 1 and 2 are worth no more than their share of the fee
+	assert.Len(plan.Skipped, 2)
+	assert.Len(plan.Transactions, 3)
+	assert.Len(plan.Transactions[2].Inputs, 40-2*MAX_INPUTS)
+	for _, tx := range plan.Transactions {
+		assert.Nil(tx.Proposal)
+		assert.Equal(uint64(len(tx.Inputs))*100-32, tx.Output)
+	}
+	assert.Equal(uint64(3*32), plan.Fee)
+	assert.Equal(42, plan.OutputsBefore)
+	assert.Equal(5, plan.OutputsAfter)
+	assert.Equal(uint64(MAX_INPUTS*100-32), plan.MaxSpendBefore)
+	assert.Equal(uint64(40*100-3*32+3-32), plan.MaxSpendAfter)
+
+	opts.MaxTransactions = 1
+	plan, err = Consolidate(utxos, opts)
+	assert.Nil(err)
+	assert.Len(plan.Transactions, 1)
+	assert.Equal(42-MAX_INPUTS+1, plan.OutputsAfter)
+}
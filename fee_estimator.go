@@ -0,0 +1,210 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// FeeEstimator prices a transaction from the number of its inputs and
+// outputs.
+type FeeEstimator interface {
+	MinimumFee() (uint64, error)
+	EstimateFee(numInputs, numOutputs int) (uint64, error)
+}
+
+// StaticFeeEstimator charges Fee, or MINIMUM_FEE when Fee is 0, plus
+// FeePerKB for every started kilobyte of the transaction.
+type StaticFeeEstimator struct {
+	Fee      uint64
+	FeePerKB uint64
+}
+
+func (e *StaticFeeEstimator) MinimumFee() (uint64, error) {
+	if e.Fee == 0 {
+		return MINIMUM_FEE, nil
+	}
+	return e.Fee, nil
+}
+
+func (e *StaticFeeEstimator) EstimateFee(numInputs, numOutputs int) (uint64, error) {
+	minimum, err := e.MinimumFee()
+	if err != nil {
+		return 0, err
+	}
+	return sizeAwareFee(minimum, e.FeePerKB, numInputs, numOutputs)
+}
+
+// LastBlockInfo is the consensus LastBlockInfoResponse.
+type LastBlockInfo struct {
+	Index      uint64
+	MinimumFee uint64
+}
+
+type LastBlockInfoSource interface {
+	GetLastBlockInfo(ctx context.Context) (*LastBlockInfo, error)
+}
+
+// NetworkFeeEstimator reads the minimum fee of the consensus network from
+// Source, the fee is cached for CacheDuration, 0 for no cache.
+type NetworkFeeEstimator struct {
+	Source        LastBlockInfoSource
+	FeePerKB      uint64
+	CacheDuration time.Duration
+
+	mutex     sync.Mutex
+	fee       uint64
+	fetchedAt time.Time
+}
+
+func NewNetworkFeeEstimator(source LastBlockInfoSource, cacheDuration time.Duration) *NetworkFeeEstimator {
+	return &NetworkFeeEstimator{Source: source, CacheDuration: cacheDuration}
+}
+
+func (e *NetworkFeeEstimator) MinimumFee() (uint64, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.fee > 0 && time.Since(e.fetchedAt) < e.CacheDuration {
+		return e.fee, nil
+	}
+	if e.Source == nil {
+		return 0, errors.New("Invalid fee estimator, source required")
+	}
+	info, err := e.Source.GetLastBlockInfo(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	e.fee = info.MinimumFee
+	// nodes which predate the configurable fee leave it unset
+	if e.fee == 0 {
+		e.fee = MINIMUM_FEE
+	}
+	e.fetchedAt = time.Now()
+	return e.fee, nil
+}
+
+func (e *NetworkFeeEstimator) EstimateFee(numInputs, numOutputs int) (uint64, error) {
+	minimum, err := e.MinimumFee()
+	if err != nil {
+		return 0, err
+	}
+	return sizeAwareFee(minimum, e.FeePerKB, numInputs, numOutputs)
+}
+
+func sizeAwareFee(minimum, feePerKB uint64, numInputs, numOutputs int) (uint64, error) {
+	if numInputs < 1 || numInputs > MAX_INPUTS {
+		return 0, fmt.Errorf("Invalid inputs count %d", numInputs)
+	}
+	if numOutputs < 1 || numOutputs > MAX_OUTPUTS {
+		return 0, fmt.Errorf("Invalid outputs count %d", numOutputs)
+	}
+	size := EstimateTxSize(numInputs, numOutputs, RING_SIZE)
+	return minimum + feePerKB*uint64((size+1023)/1024), nil
+}
+
+// ConsensusBlockchainClient calls the BlockchainAPI of a consensus node,
+// which unlike the client API is not attested.
+type ConsensusBlockchainClient struct {
+	conn *grpc.ClientConn
+}
+
+func NewConsensusBlockchainClient(conn *grpc.ClientConn) *ConsensusBlockchainClient {
+	return &ConsensusBlockchainClient{conn: conn}
+}
+
+// DialConsensusBlockchain connects to a consensus node uri, e.g.
+// mc://node1.prod.mobilecoinww.com
+func DialConsensusBlockchain(address string) (*ConsensusBlockchainClient, error) {
+	uri, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use system RootCAs
+	creds := credentials.NewTLS(&tls.Config{})
+	conn, err := grpc.Dial(fmt.Sprintf("%s:443", uri.Host), grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	return NewConsensusBlockchainClient(conn), nil
+}
+
+func (c *ConsensusBlockchainClient) Close() error {
+	return c.conn.Close()
+}
+
+func (c *ConsensusBlockchainClient) GetLastBlockInfo(ctx context.Context) (*LastBlockInfo, error) {
+	info := &LastBlockInfo{}
+	err := c.conn.Invoke(ctx, "/consensus_common.BlockchainAPI/GetLastBlockInfo", &struct{}{}, info, grpc.ForceCodec(lastBlockInfoCodec{}))
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// lastBlockInfoCodec encodes the google.protobuf.Empty request and the
+// LastBlockInfoResponse, there are no generated types for consensus_common.
+type lastBlockInfoCodec struct{}
+
+func (lastBlockInfoCodec) Name() string {
+	return "proto"
+}
+
+func (lastBlockInfoCodec) Marshal(v interface{}) ([]byte, error) {
+	info, ok := v.(*LastBlockInfo)
+	if !ok {
+		return []byte{}, nil
+	}
+	var b []byte
+	if info.Index != 0 {
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, info.Index)
+	}
+	if info.MinimumFee != 0 {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, info.MinimumFee)
+	}
+	return b, nil
+}
+
+func (lastBlockInfoCodec) Unmarshal(data []byte, v interface{}) error {
+	info, ok := v.(*LastBlockInfo)
+	if !ok {
+		return nil
+	}
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		if typ == protowire.VarintType && (num == 1 || num == 2) {
+			value, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+			if num == 1 {
+				info.Index = value
+			} else {
+				info.MinimumFee = value
+			}
+			continue
+		}
+		n = protowire.ConsumeFieldValue(num, typ, data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+	}
+	return nil
+}
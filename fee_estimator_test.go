@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type testBlockchainServer struct {
+	info  *LastBlockInfo
+	calls int
+}
+
+func (s *testBlockchainServer) serve(t *testing.T) *ConsensusBlockchainClient {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(grpc.ForceServerCodec(lastBlockInfoCodec{}))
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "consensus_common.BlockchainAPI",
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "GetLastBlockInfo",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+				if err := dec(&struct{}{}); err != nil {
+					return nil, err
+				}
+				s.calls++
+				return s.info, nil
+			},
+		}},
+	}, s)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return listener.Dial()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewConsensusBlockchainClient(conn)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+type failingBlockInfoSource struct{}
+
+func (failingBlockInfoSource) GetLastBlockInfo(ctx context.Context) (*LastBlockInfo, error) {
+	return nil, errors.New("unavailable")
+}
+
+func TestStaticFeeEstimator(t *testing.T) {
+	assert := assert.New(t)
+
+	fee, err := (&StaticFeeEstimator{}).EstimateFee(1, 2)
+	assert.Nil(err)
+	assert.Equal(uint64(MINIMUM_FEE), fee)
+
+	e := &StaticFeeEstimator{Fee: 1000, FeePerKB: 10}
+	small, err := e.EstimateFee(1, 2)
+	assert.Nil(err)
+	large, err := e.EstimateFee(MAX_INPUTS, MAX_OUTPUTS)
+	assert.Nil(err)
+	assert.True(small > 1000)
+	assert.True(large > small)
+	assert.Equal(uint64(1000+10*((EstimateTxSize(1, 2, RING_SIZE)+1023)/1024)), small)
+
+	_, err = e.EstimateFee(0, 2)
+	assert.NotNil(err)
+	_, err = e.EstimateFee(1, MAX_OUTPUTS+1)
+	assert.NotNil(err)
+}
+
+func TestNetworkFeeEstimator(t *testing.T) {
+	assert := assert.New(t)
+
+	server := &testBlockchainServer{info: &LastBlockInfo{Index: 1234, MinimumFee: 10_000_000_000}}
+	client := server.serve(t)
+	info, err := client.GetLastBlockInfo(context.Background())
+	assert.Nil(err)
+	assert.Equal(uint64(1234), info.Index)
+	assert.Equal(uint64(10_000_000_000), info.MinimumFee)
+
+	e := NewNetworkFeeEstimator(client, time.Minute)
+	fee, err := e.EstimateFee(2, 2)
+	assert.Nil(err)
+	assert.Equal(uint64(10_000_000_000), fee)
+	fee, err = e.MinimumFee()
+	assert.Nil(err)
+	assert.Equal(uint64(10_000_000_000), fee)
+	assert.Equal(2, server.calls)
+
+	server.info = &LastBlockInfo{Index: 1235}
+	e = NewNetworkFeeEstimator(client, 0)
+	fee, err = e.MinimumFee()
+	assert.Nil(err)
+	assert.Equal(uint64(MINIMUM_FEE), fee)
+
+	_, err = NewNetworkFeeEstimator(failingBlockInfoSource{}, time.Minute).EstimateFee(1, 2)
+	assert.NotNil(err)
+	_, err = (&NetworkFeeEstimator{}).MinimumFee()
+	assert.NotNil(err)
+}
+
+func TestPlanBatchPaymentFeeEstimator(t *testing.T) {
+	assert := assert.New(t)
+
+	_, address := newTestAccount()
+	values := make([]uint64, 20)
+	for i := range values {
+		values[i] = 1000
+	}
+	utxos := newTestUnspentTxOuts(values...)
+	outlay := &Outlay{Value: "5000", Receiver: address}
+	opts := &BatchPaymentOptions{ChangeAddress: address, FeeEstimator: &StaticFeeEstimator{Fee: 1, FeePerKB: 1}}
+
+	plan, err := PlanBatchPayment([]*Outlay{outlay}, utxos, opts)
+	assert.Nil(err)
+	tx := plan.Transactions[0]
+	fee, err := opts.FeeEstimator.EstimateFee(len(tx.Inputs), 2)
+	assert.Nil(err)
+	assert.Equal(fee, tx.Fee)
+	assert.True(uint64(len(tx.Inputs))*1000 >= 5000+tx.Fee)
+
+	consolidation, err := Consolidate(utxos, &ConsolidationOptions{Address: address, DryRun: true, FeeEstimator: &StaticFeeEstimator{Fee: 1, FeePerKB: 1}})
+	assert.Nil(err)
+	for _, tx := range consolidation.Transactions {
+		fee, err := opts.FeeEstimator.EstimateFee(len(tx.Inputs), 1)
+		assert.Nil(err)
+		assert.Equal(fee, tx.Fee)
+	}
+}
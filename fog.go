@@ -2,11 +2,13 @@ package api
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/tls"
 	_ "embed"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"math"
 	"net/url"
 	"unsafe"
@@ -39,19 +41,25 @@ const (
 )
 
 // generate an EncryptedFogHint
-func fakeOnetimeHint() ([]byte, error) {
+func fakeOnetimeHint(rng io.Reader) ([]byte, error) {
 	plaintext := make([]byte, EncryptedFogHintSize-FooterSize)
-	var key ristretto.Point
-	key.Rand()
-	return encryptFixedLength(&key, plaintext)
+	key, err := randomPoint(rng)
+	if err != nil {
+		return nil, err
+	}
+	return encryptFixedLength(key, plaintext, rng)
 }
 
 // https://github.com/mobilecoinfoundation/mobilecoin/blob/9f3191d7c3027385b72863cea74e1fdab0525130/transaction/std/src/transaction_builder.rs#L402
 // create_fog_hint
 func CreateFogHint(recipient *account.PublicAddress) ([]byte, uint64, error) {
+	return CreateFogHintWithRNG(recipient, rand.Reader)
+}
+
+func CreateFogHintWithRNG(recipient *account.PublicAddress, rng io.Reader) ([]byte, uint64, error) {
 	// fog_report_url is none
 	if len(recipient.FogReportUrl) == 0 {
-		hint, err := fakeOnetimeHint()
+		hint, err := fakeOnetimeHint(rng)
 		if err != nil {
 			return nil, 0, err
 		}
@@ -74,7 +82,7 @@ func CreateFogHint(recipient *account.PublicAddress) ([]byte, uint64, error) {
 		plaintext[i] = MAGIC_NUMBER
 	}
 
-	encrypted_fog_hint, err := encryptFixedLength(&pubkey.pubkey, plaintext)
+	encrypted_fog_hint, err := encryptFixedLength(&pubkey.pubkey, plaintext, rng)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -278,9 +286,7 @@ func GetFogPubkeyRust(recipient *account.PublicAddress) (*FogFullyValidatedPubke
 		if mc_error == nil {
 			return nil, errors.New("mc_fog_resolver_add_report_response failed")
 		} else {
-			err = fmt.Errorf("mc_fog_resolver_add_report_response failed: [%d] %s", mc_error.error_code, C.GoString(mc_error.error_description))
-			C.mc_error_free(mc_error)
-			return nil, err
+			return nil, newMcError("mc_fog_resolver_add_report_response", mc_error)
 		}
 	}
 
@@ -304,9 +310,7 @@ func GetFogPubkeyRust(recipient *account.PublicAddress) (*FogFullyValidatedPubke
 		if mc_error == nil {
 			return nil, errors.New("get_fog_pubkey failed: no error returned?!")
 		} else {
-			err = fmt.Errorf("get_fog_pubkey failed: [%d] %s", mc_error.error_code, C.GoString(mc_error.error_description))
-			C.mc_error_free(mc_error)
-			return nil, err
+			return nil, newMcError("get_fog_pubkey", mc_error)
 		}
 	}
 	defer C.mc_fully_validated_fog_pubkey_free(fully_validated_fog_pub_key)
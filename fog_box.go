@@ -3,6 +3,7 @@ package api
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"io"
 
 	"github.com/bwesterb/go-ristretto"
 	"github.com/dchest/blake2b"
@@ -10,15 +11,17 @@ import (
 )
 
 // our public, shared_secret
-func newSecret(pub *ristretto.Point) (*ristretto.Point, *ristretto.Point) {
-	var r ristretto.Scalar
-	r.Rand()
+func newSecret(pub *ristretto.Point, rng io.Reader) (*ristretto.Point, *ristretto.Point, error) {
+	r, err := randomScalar(rng)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	var p ristretto.Point
-	p.ScalarMultBase(&r)
+	p.ScalarMultBase(r)
 
 	var share ristretto.Point
-	return &p, share.ScalarMult(pub, &r)
+	return &p, share.ScalarMult(pub, r), nil
 }
 
 /// This part must produce the key and IV/nonce for aes-gcm
@@ -50,9 +53,12 @@ func encryptInPlaceDetachedInAead(key, nonce, buffer []byte) ([]byte, []byte, er
 }
 
 // Footersize = 50, + 32 for one curve point, + 2 bytes of magic / padding space for future needs
-func encryptInPlaceDetached(pub *ristretto.Point, buffer []byte) ([]byte, []byte, error) {
+func encryptInPlaceDetached(pub *ristretto.Point, buffer []byte, rng io.Reader) ([]byte, []byte, error) {
 	// ECDH
-	ourPublic, sharedSecret := newSecret(pub)
+	ourPublic, sharedSecret, err := newSecret(pub, rng)
+	if err != nil {
+		return nil, nil, err
+	}
 	curve_point_bytes := ourPublic.Bytes()
 
 	// KDF key 32 & nonce 12 of aes Aes256Gcm
@@ -70,8 +76,8 @@ func encryptInPlaceDetached(pub *ristretto.Point, buffer []byte) ([]byte, []byte
 	return curve_point_bytes, buffer, nil
 }
 
-func encryptFixedLength(pub *ristretto.Point, buffer []byte) ([]byte, error) {
-	footer, buffer, err := encryptInPlaceDetached(pub, buffer)
+func encryptFixedLength(pub *ristretto.Point, buffer []byte, rng io.Reader) ([]byte, error) {
+	footer, buffer, err := encryptInPlaceDetached(pub, buffer, rng)
 	if err != nil {
 		return nil, err
 	}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"crypto/rand"
 	"encoding/hex"
 	"fmt"
 	"log"
@@ -11,7 +12,7 @@ import (
 
 func TestFogAddress_Testnet(t *testing.T) {
 	assert := assert.New(t)
-	hint, err := fakeOnetimeHint()
+	hint, err := fakeOnetimeHint(rand.Reader)
 	assert.Equal(err, nil)
 	assert.Equal(EncryptedFogHintSize, len(hint))
 
@@ -35,7 +36,7 @@ func TestFogAddress_Testnet(t *testing.T) {
 
 func TestFogAddress_MobileCoinMainnet(t *testing.T) {
 	assert := assert.New(t)
-	hint, err := fakeOnetimeHint()
+	hint, err := fakeOnetimeHint(rand.Reader)
 	assert.Equal(err, nil)
 	assert.Equal(EncryptedFogHintSize, len(hint))
 
@@ -59,7 +60,7 @@ func TestFogAddress_MobileCoinMainnet(t *testing.T) {
 
 func TestFogAddress_SignalMainnet(t *testing.T) {
 	assert := assert.New(t)
-	hint, err := fakeOnetimeHint()
+	hint, err := fakeOnetimeHint(rand.Reader)
 	assert.Equal(err, nil)
 	assert.Equal(EncryptedFogHintSize, len(hint))
 
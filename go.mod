@@ -8,7 +8,7 @@ require (
 	github.com/bwesterb/go-ristretto v1.2.0
 	github.com/dchest/blake2b v1.0.0
 	github.com/gtank/merlin v0.1.1
-	github.com/jadeydi/mobilecoin-account v0.0.0-20211027145828-01097478ea7a // indirect
+	github.com/jadeydi/mobilecoin-account v0.0.0-20211027145828-01097478ea7a
 	github.com/stretchr/testify v1.7.0
 	golang.org/x/crypto v0.0.0-20201221181555-eec23a3978ad
 	golang.org/x/net v0.0.0-20211020060615-d418f374d309 // indirect
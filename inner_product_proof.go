@@ -210,3 +210,130 @@ func vartimeMultiscalarMul(scalars []*ristretto.Scalar, points []*ristretto.Poin
 	}
 	return &r
 }
+
+// InnerProductProofFromBytes parses the encoding of InnerProductProof.ToBytes
+// with the same canonical checks as RangeProofFromBytes.
+func InnerProductProofFromBytes(buf []byte) (*InnerProductProof, error) {
+	if len(buf)%32 != 0 || len(buf) < 64 {
+		return nil, fmt.Errorf("Invalid inner product proof length %d", len(buf))
+	}
+	num := len(buf) / 32
+	if (num-2)%2 != 0 {
+		return nil, fmt.Errorf("Invalid inner product proof length %d", len(buf))
+	}
+	lgN := (num - 2) / 2
+	if lgN >= 32 {
+		return nil, fmt.Errorf("Invalid inner product proof length %d", len(buf))
+	}
+
+	proof := &InnerProductProof{
+		LVec: make([]*ristretto.Point, lgN),
+		RVec: make([]*ristretto.Point, lgN),
+	}
+	var err error
+	for i := 0; i < lgN; i++ {
+		proof.LVec[i], err = pointFromBytes(buf[64*i : 64*i+32])
+		if err != nil {
+			return nil, err
+		}
+		proof.RVec[i], err = pointFromBytes(buf[64*i+32 : 64*i+64])
+		if err != nil {
+			return nil, err
+		}
+	}
+	pos := 64 * lgN
+	proof.A, err = scalarFromBytes(buf[pos : pos+32])
+	if err != nil {
+		return nil, err
+	}
+	proof.B, err = scalarFromBytes(buf[pos+32 : pos+64])
+	if err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+// verificationScalars returns the squared challenges u_i^2, their inverses
+// and the vector s computed from them, it is the verifier side of
+// CreateInnerProductProof.
+func (p *InnerProductProof) verificationScalars(n int, transcript *merlin.Transcript) ([]*ristretto.Scalar, []*ristretto.Scalar, []*ristretto.Scalar, error) {
+	lgN := len(p.LVec)
+	if lgN >= 32 || len(p.RVec) != lgN || n != 1<<lgN {
+		return nil, nil, nil, fmt.Errorf("Invalid inner product proof size %d, %d", lgN, n)
+	}
+
+	InnerproductDomainSep(uint64(n), transcript)
+
+	challenges := make([]*ristretto.Scalar, lgN)
+	for i := range p.LVec {
+		AppendPoint("L", p.LVec[i], transcript)
+		AppendPoint("R", p.RVec[i], transcript)
+		challenges[i] = ChallengeScalar("u", transcript)
+	}
+
+	var allInv ristretto.Scalar
+	allInv.SetOne()
+	challengesSq := make([]*ristretto.Scalar, lgN)
+	challengesInvSq := make([]*ristretto.Scalar, lgN)
+	for i, u := range challenges {
+		var uInv, uSq, uInvSq ristretto.Scalar
+		uInv.Inverse(u)
+		allInv.Mul(&allInv, &uInv)
+		challengesSq[i] = uSq.Mul(u, u)
+		challengesInvSq[i] = uInvSq.Mul(&uInv, &uInv)
+	}
+
+	s := make([]*ristretto.Scalar, n)
+	s[0] = &allInv
+	for i := 1; i < n; i++ {
+		lgI := 31 - bits.LeadingZeros32(uint32(i))
+		k := 1 << lgI
+		var si ristretto.Scalar
+		s[i] = si.Mul(s[i-k], challengesSq[(lgN-1)-lgI])
+	}
+	return challengesSq, challengesInvSq, s, nil
+}
+
+// https://github.com/dalek-cryptography/bulletproofs/blob/main/src/inner_product_proof.rs
+// verify
+func (p *InnerProductProof) Verify(n int, transcript *merlin.Transcript, gFactors, hFactors []*ristretto.Scalar, P, Q *ristretto.Point, G, H []*ristretto.Point) error {
+	if len(gFactors) != n || len(hFactors) != n || len(G) != n || len(H) != n {
+		return fmt.Errorf("Invalid input vectors %d, %d, %d, %d, %d", n, len(gFactors), len(hFactors), len(G), len(H))
+	}
+	uSq, uInvSq, s, err := p.verificationScalars(n, transcript)
+	if err != nil {
+		return err
+	}
+
+	var ab ristretto.Scalar
+	ab.Mul(p.A, p.B)
+	scalars := []*ristretto.Scalar{&ab}
+	points := []*ristretto.Point{Q}
+	for i := 0; i < n; i++ {
+		var g ristretto.Scalar
+		g.Mul(p.A, s[i])
+		scalars = append(scalars, g.Mul(&g, gFactors[i]))
+	}
+	points = append(points, G...)
+	for i := 0; i < n; i++ {
+		var h ristretto.Scalar
+		h.Mul(p.B, s[n-1-i])
+		scalars = append(scalars, h.Mul(&h, hFactors[i]))
+	}
+	points = append(points, H...)
+	for i := range uSq {
+		var r ristretto.Scalar
+		scalars = append(scalars, r.Neg(uSq[i]))
+	}
+	points = append(points, p.LVec...)
+	for i := range uInvSq {
+		var r ristretto.Scalar
+		scalars = append(scalars, r.Neg(uInvSq[i]))
+	}
+	points = append(points, p.RVec...)
+
+	if !vartimeMultiscalarMul(scalars, points).Equals(P) {
+		return ErrProofVerification
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package api
+
+import "fmt"
+
+// https://github.com/mobilecoinfoundation/mobilecoin/blob/master/libmobilecoin/include/common.h
+type McErrorCode int
+
+const (
+	McErrorCodeUnknown                       McErrorCode = -1
+	McErrorCodePanic                         McErrorCode = -2
+	McErrorCodeInvalidInput                  McErrorCode = 100
+	McErrorCodeInvalidOutput                 McErrorCode = 101
+	McErrorCodeAttestationVerificationFailed McErrorCode = 200
+	McErrorCodeAead                          McErrorCode = 300
+	McErrorCodeCipher                        McErrorCode = 301
+	McErrorCodeUnsupportedCryptoBoxVersion   McErrorCode = 302
+	McErrorCodeTransactionCrypto             McErrorCode = 400
+	McErrorCodeFogPubkey                     McErrorCode = 500
+)
+
+var mcErrorCodeNames = map[McErrorCode]string{
+	McErrorCodeUnknown:                       "Unknown",
+	McErrorCodePanic:                         "Panic",
+	McErrorCodeInvalidInput:                  "InvalidInput",
+	McErrorCodeInvalidOutput:                 "InvalidOutput",
+	McErrorCodeAttestationVerificationFailed: "AttestationVerificationFailed",
+	McErrorCodeAead:                          "Aead",
+	McErrorCodeCipher:                        "Cipher",
+	McErrorCodeUnsupportedCryptoBoxVersion:   "UnsupportedCryptoBoxVersion",
+	McErrorCodeTransactionCrypto:             "TransactionCrypto",
+	McErrorCodeFogPubkey:                     "FogPubkey",
+}
+
+func (c McErrorCode) String() string {
+	if name, ok := mcErrorCodeNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("McErrorCode(%d)", int(c))
+}
+
+// McError is an error returned by a libmobilecoin function, use
+// errors.Is(err, &McError{Code: McErrorCodeAttestationVerificationFailed})
+// to match a code.
+type McError struct {
+	Function    string
+	Code        McErrorCode
+	Description string
+}
+
+func (e *McError) Error() string {
+	return fmt.Sprintf("%s failed: [%d] %s", e.Function, int(e.Code), e.Description)
+}
+
+func (e *McError) Is(target error) bool {
+	t, ok := target.(*McError)
+	return ok && t.Code == e.Code
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMcError(t *testing.T) {
+	assert := assert.New(t)
+
+	err := fmt.Errorf("attest: %w", &McError{
+		Function:    "mc_attest_ake_process_auth_response",
+		Code:        McErrorCodeAttestationVerificationFailed,
+		Description: "MrEnclave mismatch",
+	})
+	assert.True(errors.Is(err, &McError{Code: McErrorCodeAttestationVerificationFailed}))
+	assert.False(errors.Is(err, &McError{Code: McErrorCodeInvalidInput}))
+	assert.Equal("attest: mc_attest_ake_process_auth_response failed: [200] MrEnclave mismatch", err.Error())
+	assert.Equal("Aead", McErrorCodeAead.String())
+	assert.Equal("McErrorCode(7)", McErrorCode(7).String())
+}
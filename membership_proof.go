@@ -0,0 +1,192 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/dchest/blake2b"
+)
+
+// https://github.com/mobilecoinfoundation/mobilecoin/blob/master/transaction/core/src/domain_separators.rs
+const (
+	TXOUT_MERKLE_LEAF_DOMAIN_TAG = "mc_tx_out_merkle_leaf"
+	TXOUT_MERKLE_NODE_DOMAIN_TAG = "mc_tx_out_merkle_node"
+	TXOUT_MERKLE_NIL_DOMAIN_TAG  = "mc_tx_out_merkle_nil"
+)
+
+// HashOfTxOut is the leaf of txOut in the Merkle tree of the ledger, the
+// hash of its digest. Like TxOut.Hash it has no external test vectors yet,
+// the leaves and roots are not known to match those of the ledger.
+// https://github.com/mobilecoinfoundation/mobilecoin/blob/master/transaction/core/src/membership_proofs/mod.rs
+// hash_leaf
+func HashOfTxOut(txOut *TxOut) (TxOutMembershipHash, error) {
+	var leaf TxOutMembershipHash
+	hash, err := txOut.Hash()
+	if err != nil {
+		return leaf, err
+	}
+	h := blake2b.New256()
+	h.Write([]byte(TXOUT_MERKLE_LEAF_DOMAIN_TAG))
+	h.Write(hash[:])
+	copy(leaf[:], h.Sum(nil))
+	return leaf, nil
+}
+
+// hash_nodes
+func hashMerkleNodes(left, right TxOutMembershipHash) TxOutMembershipHash {
+	h := blake2b.New256()
+	h.Write([]byte(TXOUT_MERKLE_NODE_DOMAIN_TAG))
+	h.Write(left[:])
+	h.Write(right[:])
+	var node TxOutMembershipHash
+	copy(node[:], h.Sum(nil))
+	return node
+}
+
+// hash_nil, the hash of the ranges past the last tx out of the ledger.
+func hashMerkleNil() TxOutMembershipHash {
+	h := blake2b.New256()
+	h.Write([]byte(TXOUT_MERKLE_NIL_DOMAIN_TAG))
+	var nil_hash TxOutMembershipHash
+	copy(nil_hash[:], h.Sum(nil))
+	return nil_hash
+}
+
+// VerifyMembershipProof checks that proof places txOut in the ledger whose
+// Merkle root is rootElement. The first element of the proof is the leaf
+// of txOut, each following one the sibling of the range covered so far.
+func VerifyMembershipProof(txOut *TxOut, proof *TxOutMembershipProof, rootElement *TxOutMembershipElement) error {
+	if rootElement == nil {
+		return validationError(InvalidTxOutMembershipProof, "missing root element")
+	}
+	rootFrom, rootTo, err := parseMerkleRange(rootElement.Range)
+	if err != nil {
+		return err
+	}
+	root, err := membershipProofRoot(txOut, proof)
+	if err != nil {
+		return err
+	}
+	if root.Range.From != strconv.FormatUint(rootFrom, 10) || root.Range.To != strconv.FormatUint(rootTo, 10) {
+		return validationError(InvalidTxOutMembershipProof, "root range [%s, %s], expected [%d, %d]", root.Range.From, root.Range.To, rootFrom, rootTo)
+	}
+	if root.Hash != rootElement.Hash {
+		return validationError(InvalidTxOutMembershipProof, "root hash %s, expected %s", root.Hash, rootElement.Hash)
+	}
+	return nil
+}
+
+// TxInMembershipRoot returns the root implied by the proofs of the ring of
+// input, they must all share it. Comparing it to the root of a trusted
+// ledger catches bad or stale proofs before signing.
+func TxInMembershipRoot(input *TxIn) (*TxOutMembershipElement, error) {
+	if len(input.Ring) == 0 {
+		return nil, validationError(InsufficientRingSize, "empty ring")
+	}
+	if len(input.Proofs) != len(input.Ring) {
+		return nil, validationError(MissingTxOutMembershipProof, "%d proofs for ring of %d", len(input.Proofs), len(input.Ring))
+	}
+	var root *TxOutMembershipElement
+	for i, txOut := range input.Ring {
+		r, err := membershipProofRoot(txOut, input.Proofs[i])
+		if err != nil {
+			return nil, err
+		}
+		if root == nil {
+			root = r
+			continue
+		}
+		if *r.Range != *root.Range || r.Hash != root.Hash {
+			return nil, validationError(InvalidTxOutMembershipProof, "ring element %s root %s, expected %s", txOut.PublicKey, r.Hash, root.Hash)
+		}
+	}
+	return root, nil
+}
+
+// membershipProofRoot is the root element implied by proof and the leaf of
+// txOut, compute_implied_merkle_root.
+func membershipProofRoot(txOut *TxOut, proof *TxOutMembershipProof) (*TxOutMembershipElement, error) {
+	if txOut == nil || txOut.Amount == nil {
+		return nil, validationError(InvalidTxOutMembershipProof, "missing tx out")
+	}
+	if proof == nil || len(proof.Elements) == 0 {
+		return nil, validationError(MissingTxOutMembershipProof, "no elements")
+	}
+	index, err := strconv.ParseUint(proof.Index, 10, 64)
+	if err != nil {
+		return nil, validationError(InvalidTxOutMembershipProof, "index %s", proof.Index)
+	}
+	highestIndex, err := strconv.ParseUint(proof.HighestIndex, 10, 64)
+	if err != nil {
+		return nil, validationError(InvalidTxOutMembershipProof, "highest index %s", proof.HighestIndex)
+	}
+	if index > highestIndex {
+		return nil, validationError(InvalidTxOutMembershipProof, "index %d > highest index %d", index, highestIndex)
+	}
+
+	leaf := proof.Elements[0]
+	from, to, err := parseMerkleRange(leaf.Range)
+	if err != nil {
+		return nil, err
+	}
+	if from != index || to != index {
+		return nil, validationError(InvalidTxOutMembershipProof, "leaf range [%d, %d], expected [%d, %d]", from, to, index, index)
+	}
+	leafHash, err := HashOfTxOut(txOut)
+	if err != nil {
+		return nil, validationError(InvalidTxOutMembershipProof, "tx out %s: %s", txOut.PublicKey, err)
+	}
+	if leaf.Hash != leafHash {
+		return nil, validationError(InvalidTxOutMembershipProof, "leaf hash of tx out %s", txOut.PublicKey)
+	}
+
+	hash := leaf.Hash
+	for _, element := range proof.Elements[1:] {
+		size := to - from + 1
+		if size > 1<<62 {
+			return nil, validationError(InvalidTxOutMembershipProof, "range [%d, %d] too large", from, to)
+		}
+		siblingFrom, siblingTo, err := parseMerkleRange(element.Range)
+		if err != nil {
+			return nil, err
+		}
+		if from%(2*size) == 0 {
+			if siblingFrom != to+1 || siblingTo != to+size {
+				return nil, validationError(InvalidTxOutMembershipProof, "range [%d, %d], expected [%d, %d]", siblingFrom, siblingTo, to+1, to+size)
+			}
+			hash = hashMerkleNodes(hash, element.Hash)
+			to = siblingTo
+		} else {
+			if siblingFrom != from-size || siblingTo != from-1 {
+				return nil, validationError(InvalidTxOutMembershipProof, "range [%d, %d], expected [%d, %d]", siblingFrom, siblingTo, from-size, from-1)
+			}
+			hash = hashMerkleNodes(element.Hash, hash)
+			from = siblingFrom
+		}
+	}
+	if from != 0 || to < highestIndex {
+		return nil, validationError(InvalidTxOutMembershipProof, "root range [%d, %d] misses highest index %d", from, to, highestIndex)
+	}
+
+	return &TxOutMembershipElement{
+		Range: &Range{From: strconv.FormatUint(from, 10), To: strconv.FormatUint(to, 10)},
+		Hash:  hash,
+	}, nil
+}
+
+func parseMerkleRange(r *Range) (uint64, uint64, error) {
+	if r == nil {
+		return 0, 0, validationError(InvalidTxOutMembershipProof, "element without range")
+	}
+	from, err := strconv.ParseUint(r.From, 10, 64)
+	if err != nil {
+		return 0, 0, validationError(InvalidTxOutMembershipProof, "range from %s", r.From)
+	}
+	to, err := strconv.ParseUint(r.To, 10, 64)
+	if err != nil {
+		return 0, 0, validationError(InvalidTxOutMembershipProof, "range to %s", r.To)
+	}
+	if to < from {
+		return 0, 0, validationError(InvalidTxOutMembershipProof, "range [%d, %d]", from, to)
+	}
+	return from, to, nil
+}
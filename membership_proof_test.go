@@ -0,0 +1,151 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testMerkleHash is the hash of the range [from, to] of a ledger of leaves,
+// the ranges past the last leaf hash to nil.
+func testMerkleHash(leaves []TxOutMembershipHash, from, to uint64) TxOutMembershipHash {
+	if from >= uint64(len(leaves)) {
+		return hashMerkleNil()
+	}
+	if from == to {
+		return leaves[from]
+	}
+	mid := from + (to-from+1)/2
+	return hashMerkleNodes(testMerkleHash(leaves, from, mid-1), testMerkleHash(leaves, mid, to))
+}
+
+func testMerkleElement(leaves []TxOutMembershipHash, from, to uint64) *TxOutMembershipElement {
+	return &TxOutMembershipElement{
+		Range: &Range{From: strconv.FormatUint(from, 10), To: strconv.FormatUint(to, 10)},
+		Hash:  testMerkleHash(leaves, from, to),
+	}
+}
+
+func testMembershipProof(leaves []TxOutMembershipHash, index, rootTo uint64) *TxOutMembershipProof {
+	proof := &TxOutMembershipProof{
+		Index:        strconv.FormatUint(index, 10),
+		HighestIndex: strconv.Itoa(len(leaves) - 1),
+		Elements:     []*TxOutMembershipElement{testMerkleElement(leaves, index, index)},
+	}
+	from, to := index, index
+	for to-from < rootTo {
+		size := to - from + 1
+		if from%(2*size) == 0 {
+			proof.Elements = append(proof.Elements, testMerkleElement(leaves, to+1, to+size))
+			to += size
+		} else {
+			proof.Elements = append(proof.Elements, testMerkleElement(leaves, from-size, from-1))
+			from -= size
+		}
+	}
+	return proof
+}
+
+func TestVerifyMembershipProof(t *testing.T) {
+	assert := assert.New(t)
+
+	var txOuts []*TxOut
+	var leaves []TxOutMembershipHash
+	for i := 0; i < 5; i++ {
+		_, address := newTestAccount()
+		output, _, err := CreateOutput(MILLIMOB_TO_PICOMOB, address, 0)
+		assert.Nil(err)
+		txOuts = append(txOuts, output.Output)
+		leaves = append(leaves, txOutLeaf(output.Output))
+	}
+	root := testMerkleElement(leaves, 0, 7)
+	input := &TxIn{}
+	for i, txOut := range txOuts {
+		proof := testMembershipProof(leaves, uint64(i), 7)
+		assert.Len(proof.Elements, 4)
+		assert.Nil(VerifyMembershipProof(txOut, proof, root))
+		input.Ring = append(input.Ring, txOut)
+		input.Proofs = append(input.Proofs, proof)
+	}
+	implied, err := TxInMembershipRoot(input)
+	assert.Nil(err)
+	assert.Equal(root, implied)
+
+	isInvalid := func(err error) bool {
+		return errors.Is(err, &ValidationError{Rule: InvalidTxOutMembershipProof})
+	}
+	proof := testMembershipProof(leaves, 2, 7)
+	assert.True(isInvalid(VerifyMembershipProof(txOuts[3], proof, root)))
+	assert.True(isInvalid(VerifyMembershipProof(txOuts[2], proof, testMerkleElement(leaves[:4], 0, 7))))
+	assert.True(isInvalid(VerifyMembershipProof(txOuts[2], proof, testMerkleElement(leaves, 0, 15))))
+	assert.True(isInvalid(VerifyMembershipProof(txOuts[2], proof, &TxOutMembershipElement{})))
+	// the proof of a ledger of 4 tx outs is stale
+	stale := testMembershipProof(leaves[:4], 2, 3)
+	assert.True(isInvalid(VerifyMembershipProof(txOuts[2], stale, root)))
+
+	for _, mutate := range []func(proof *TxOutMembershipProof){
+		func(proof *TxOutMembershipProof) { proof.Elements[1].Hash[0] ^= 1 },
+		func(proof *TxOutMembershipProof) {
+			proof.Elements[1], proof.Elements[2] = proof.Elements[2], proof.Elements[1]
+		},
+		func(proof *TxOutMembershipProof) { proof.Elements[2].Range.To = "2" },
+		func(proof *TxOutMembershipProof) { proof.Elements[0].Range = nil },
+		func(proof *TxOutMembershipProof) { proof.Elements = proof.Elements[:3] },
+		func(proof *TxOutMembershipProof) { proof.Index = "3" },
+		func(proof *TxOutMembershipProof) { proof.HighestIndex = "1" },
+		func(proof *TxOutMembershipProof) { proof.HighestIndex = "8" },
+	} {
+		invalid := testMembershipProof(leaves, 2, 7)
+		mutate(invalid)
+		assert.True(isInvalid(VerifyMembershipProof(txOuts[2], invalid, root)))
+	}
+	err = VerifyMembershipProof(txOuts[2], &TxOutMembershipProof{Index: "2", HighestIndex: "4"}, root)
+	assert.True(errors.Is(err, &ValidationError{Rule: MissingTxOutMembershipProof}))
+
+	input.Proofs[4] = testMembershipProof(append(leaves, leaves[0]), 4, 7)
+	_, err = TxInMembershipRoot(input)
+	assert.True(isInvalid(err))
+	input.Proofs = input.Proofs[:4]
+	_, err = TxInMembershipRoot(input)
+	assert.True(errors.Is(err, &ValidationError{Rule: MissingTxOutMembershipProof}))
+}
+
+func TestValidateTxMembershipProofs(t *testing.T) {
+	assert := assert.New(t)
+
+	tx := newTestTx([]uint64{3 * MILLIMOB_TO_PICOMOB, 2 * MILLIMOB_TO_PICOMOB}, []uint64{4 * MILLIMOB_TO_PICOMOB, MILLIMOB_TO_PICOMOB - MINIMUM_FEE}, MINIMUM_FEE, 150)
+	isInvalid := func(err error) bool {
+		return errors.Is(err, &ValidationError{Rule: InvalidTxOutMembershipProof})
+	}
+
+	// the test proofs are placeholders without elements
+	var leaves []TxOutMembershipHash
+	for _, input := range tx.Prefix.Inputs {
+		for _, txOut := range input.Ring {
+			leaves = append(leaves, txOutLeaf(txOut))
+		}
+	}
+	root := testMerkleElement(leaves, 0, 31)
+	err := ValidateTx(tx, 100, WithMembershipRoot(root))
+	assert.True(errors.Is(err, &ValidationError{Rule: MissingTxOutMembershipProof}))
+
+	// the proofs are in the prefix hash, the signatures are skipped once
+	// they are replaced
+	for i, input := range tx.Prefix.Inputs {
+		for j := range input.Ring {
+			input.Proofs[j] = testMembershipProof(leaves, uint64(i*RING_SIZE+j), 31)
+		}
+	}
+	assert.Nil(ValidateTx(tx, 100, WithoutSignatures(), WithMembershipRoot(root)))
+	assert.Nil(ValidateTx(tx, 100, WithoutSignatures()))
+	assert.True(isInvalid(ValidateTx(tx, 100, WithoutSignatures(), WithMembershipRoot(testMerkleElement(leaves[1:], 0, 31)))))
+
+	proofs := tx.Prefix.Inputs[1].Proofs
+	tx.Prefix.Inputs[1].Proofs[0], tx.Prefix.Inputs[1].Proofs[1] = proofs[1], proofs[0]
+	assert.True(isInvalid(ValidateTx(tx, 100, WithoutSignatures(), WithMembershipRoot(root))))
+	tx.Prefix.Inputs[1].Proofs = proofs[:RING_SIZE-1]
+	err = ValidateTx(tx, 100, WithoutSignatures(), WithMembershipRoot(root))
+	assert.True(errors.Is(err, &ValidationError{Rule: MissingTxOutMembershipProof}))
+}
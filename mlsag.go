@@ -5,12 +5,13 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 
 	"github.com/bwesterb/go-ristretto"
 	"github.com/dchest/blake2b"
 )
 
-func signRing(message []byte, inputs []*TxOut, realIndex int, onetimePrivateKey *ristretto.Scalar, value uint64, blinding, outputBlinding *ristretto.Scalar) (*RingMLSAG, error) {
+func signRing(message []byte, inputs []*TxOut, realIndex int, onetimePrivateKey *ristretto.Scalar, value uint64, blinding, outputBlinding *ristretto.Scalar, rng io.Reader) (*RingMLSAG, error) {
 	size := len(inputs)
 	if realIndex >= size {
 		return nil, fmt.Errorf("Invalid inputs size %d and realIndex: %d", len(inputs), realIndex)
@@ -25,8 +26,6 @@ func signRing(message []byte, inputs []*TxOut, realIndex int, onetimePrivateKey
 
 	outputCommitment := NewCommitment(value, outputBlinding)
 
-	// decompress_ring CompressedRistrettoPublic = tx_out.target_key, CompressedCommitment = tx_out.amount.commitment
-
 	c := make([]*ristretto.Scalar, size)
 	for i := range c {
 		var zero ristretto.Scalar
@@ -38,26 +37,51 @@ func signRing(message []byte, inputs []*TxOut, realIndex int, onetimePrivateKey
 		if i == realIndex {
 			continue
 		}
-		var r1, r2 ristretto.Scalar
-		r[2*i] = r1.Rand()
-		r[2*i+1] = r2.Rand()
+		r1, err := randomScalar(rng)
+		if err != nil {
+			return nil, err
+		}
+		r2, err := randomScalar(rng)
+		if err != nil {
+			return nil, err
+		}
+		r[2*i], r[2*i+1] = r1, r2
 	}
 
-	var alpha0, alpha1 ristretto.Scalar
-	alpha0.Rand()
-	alpha1.Rand()
+	alpha0, err := randomScalar(rng)
+	if err != nil {
+		return nil, err
+	}
+	alpha1, err := randomScalar(rng)
+	if err != nil {
+		return nil, err
+	}
+
+	// decompress_ring CompressedRistrettoPublic = tx_out.target_key, CompressedCommitment = tx_out.amount.commitment
+	// P is TargetKey
+	publicKeys := make([]*ristretto.Point, size)
+	commitments := make([]*ristretto.Point, size)
+	for i := range inputs {
+		publicKeys[i], err = inputs[i].TargetKeyPoint()
+		if err != nil {
+			return nil, err
+		}
+		commitments[i], err = inputs[i].Amount.CommitmentPoint()
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	for n := 0; n < size; n++ {
 		i := (realIndex + n) % size
-		// P is TargetKey
-		p_i := hexToPoint(inputs[i].TargetKey)
-		inputCommitment := hexToPoint(inputs[i].Amount.Commitment)
+		p_i := publicKeys[i]
+		inputCommitment := commitments[i]
 
 		var L0, L1, R0 ristretto.Point
 		if i == realIndex {
-			L0.ScalarMultBase(&alpha0)
-			R0.ScalarMult(hashToPoint(p_i), &alpha0)
-			L1.ScalarMultBase(&alpha1)
+			L0.ScalarMultBase(alpha0)
+			R0.ScalarMult(hashToPoint(p_i), alpha0)
+			L1.ScalarMultBase(alpha1)
 		} else {
 			var L00, L01 ristretto.Point
 			L0.Add(L00.ScalarMultBase(r[2*i]), L01.ScalarMult(p_i, c[i]))
@@ -71,12 +95,12 @@ func signRing(message []byte, inputs []*TxOut, realIndex int, onetimePrivateKey
 	}
 
 	var s0, s1 ristretto.Scalar
-	r[2*realIndex] = s0.Sub(&alpha0, s1.Mul(c[realIndex], onetimePrivateKey))
+	r[2*realIndex] = s0.Sub(alpha0, s1.Mul(c[realIndex], onetimePrivateKey))
 	var z0, z1, z2 ristretto.Scalar
-	r[2*realIndex+1] = z0.Sub(&alpha1, z1.Mul(c[realIndex], z2.Sub(outputBlinding, blinding)))
+	r[2*realIndex+1] = z0.Sub(alpha1, z1.Mul(c[realIndex], z2.Sub(outputBlinding, blinding)))
 
 	if true {
-		inputCommitment := hexToPoint(inputs[realIndex].Amount.Commitment)
+		inputCommitment := commitments[realIndex]
 
 		var different ristretto.Point
 		different.Sub(outputCommitment, inputCommitment)
@@ -115,3 +139,77 @@ func challenge(message []byte, keyImage *ristretto.Point, L0, R0, L1 *ristretto.
 	var s ristretto.Scalar
 	return s.SetReduced(&key)
 }
+
+var (
+	ErrRingLengthMismatch   = errors.New("Ring Length Mismatch")
+	ErrInvalidCurvePoint    = errors.New("Invalid Curve Point")
+	ErrInvalidCurveScalar   = errors.New("Invalid Curve Scalar")
+	ErrInvalidKeyImage      = errors.New("Invalid Key Image")
+	ErrInvalidRingSignature = errors.New("Invalid Ring Signature")
+)
+
+// https://github.com/mobilecoinfoundation/mobilecoin/blob/master/transaction/core/src/ring_signature/mlsag.rs
+// VerifyRingMLSAG checks sig is a valid signature of message by one of the
+// ring members, with pseudoOutputCommitment committing to the same value as
+// the real input.
+func VerifyRingMLSAG(message []byte, ring []*TxOut, pseudoOutputCommitment *ristretto.Point, sig *RingMLSAG) error {
+	if sig == nil || pseudoOutputCommitment == nil {
+		return ErrInvalidRingSignature
+	}
+	size := len(ring)
+	if size == 0 || len(sig.Responses) != 2*size {
+		return ErrRingLengthMismatch
+	}
+
+	keyImage, err := decodePoint(sig.KeyImage)
+	if err != nil {
+		return ErrInvalidKeyImage
+	}
+	cZero, err := decodeScalar(sig.CZero)
+	if err != nil {
+		return ErrInvalidCurveScalar
+	}
+	r := make([]*ristretto.Scalar, len(sig.Responses))
+	for i := range sig.Responses {
+		r[i], err = decodeScalar(sig.Responses[i])
+		if err != nil {
+			return ErrInvalidCurveScalar
+		}
+	}
+
+	publicKeys := make([]*ristretto.Point, size)
+	commitments := make([]*ristretto.Point, size)
+	for i := range ring {
+		if ring[i] == nil || ring[i].Amount == nil {
+			return ErrInvalidCurvePoint
+		}
+		publicKeys[i], err = ring[i].TargetKeyPoint()
+		if err != nil {
+			return ErrInvalidCurvePoint
+		}
+		commitments[i], err = ring[i].Amount.CommitmentPoint()
+		if err != nil {
+			return ErrInvalidCurvePoint
+		}
+	}
+
+	c := cZero
+	for i := 0; i < size; i++ {
+		p_i := publicKeys[i]
+
+		var L0, L1, R0 ristretto.Point
+		var L00, L01 ristretto.Point
+		L0.Add(L00.ScalarMultBase(r[2*i]), L01.ScalarMult(p_i, c))
+		var R00, R01 ristretto.Point
+		R0.Add(R00.ScalarMult(hashToPoint(p_i), r[2*i]), R01.ScalarMult(keyImage, c))
+		var L10, L11, L12 ristretto.Point
+		L1.Add(L10.ScalarMultBase(r[2*i+1]), L11.ScalarMult(L12.Sub(pseudoOutputCommitment, commitments[i]), c))
+
+		c = challenge(message, keyImage, &L0, &R0, &L1)
+	}
+
+	if !c.Equals(cZero) {
+		return ErrInvalidRingSignature
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/bwesterb/go-ristretto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyRingMLSAG(t *testing.T) {
+	assert := assert.New(t)
+
+	acc, address := newTestAccount()
+	input := newTestInputCredential(acc, address, 7*MILLIMOB_TO_PICOMOB)
+	value, blinding := GetValueWithBlinding(input.Ring[input.RealIndex], input.ViewPrivateKey)
+	assert.Equal(uint64(7*MILLIMOB_TO_PICOMOB), value)
+
+	var outputBlinding ristretto.Scalar
+	outputBlinding.Rand()
+	pseudoOutputCommitment := NewCommitment(value, &outputBlinding)
+	message := []byte("mlsag round trip")
+
+	sig, err := signRing(message, input.Ring, input.RealIndex, input.OnetimePrivateKey, value, blinding, &outputBlinding, rand.Reader)
+	assert.Nil(err)
+	assert.Nil(VerifyRingMLSAG(message, input.Ring, pseudoOutputCommitment, sig))
+
+	assert.Equal(ErrInvalidRingSignature, VerifyRingMLSAG([]byte("another message"), input.Ring, pseudoOutputCommitment, sig))
+	assert.Equal(ErrInvalidRingSignature, VerifyRingMLSAG(message, input.Ring, NewCommitment(value+1, &outputBlinding), sig))
+
+	other := newTestInputCredential(acc, address, value)
+	assert.Equal(ErrInvalidRingSignature, VerifyRingMLSAG(message, other.Ring, pseudoOutputCommitment, sig))
+	assert.Equal(ErrRingLengthMismatch, VerifyRingMLSAG(message, input.Ring[1:], pseudoOutputCommitment, sig))
+
+	keyImage := sig.KeyImage
+	sig.KeyImage = "ff" + keyImage[2:]
+	assert.Equal(ErrInvalidKeyImage, VerifyRingMLSAG(message, input.Ring, pseudoOutputCommitment, sig))
+	sig.KeyImage = keyImage
+
+	response := sig.Responses[3]
+	sig.Responses[3] = "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"
+	assert.Equal(ErrInvalidCurveScalar, VerifyRingMLSAG(message, input.Ring, pseudoOutputCommitment, sig))
+	sig.Responses[3] = response
+
+	assert.Equal(ErrInvalidRingSignature, VerifyRingMLSAG(message, input.Ring, pseudoOutputCommitment, nil))
+	assert.Equal(ErrInvalidRingSignature, VerifyRingMLSAG(message, input.Ring, nil, sig))
+	ring := append([]*TxOut{}, input.Ring...)
+	ring[1] = &TxOut{TargetKey: ring[1].TargetKey, PublicKey: ring[1].PublicKey}
+	assert.Equal(ErrInvalidCurvePoint, VerifyRingMLSAG(message, ring, pseudoOutputCommitment, sig))
+	ring[1] = nil
+	assert.Equal(ErrInvalidCurvePoint, VerifyRingMLSAG(message, ring, pseudoOutputCommitment, sig))
+	assert.Nil(VerifyRingMLSAG(message, input.Ring, pseudoOutputCommitment, sig))
+}
@@ -1,8 +1,11 @@
 package api
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/hex"
+	"fmt"
+	"io"
 
 	"github.com/bwesterb/go-ristretto"
 	"github.com/dchest/blake2b"
@@ -68,8 +71,12 @@ func hexToPoint(h string) *ristretto.Point {
 	}
 	var buf32 [32]byte
 	copy(buf32[:], buf)
+	return bytesToPoint(buf32)
+}
+
+func bytesToPoint(buf [32]byte) *ristretto.Point {
 	var s ristretto.Point
-	s.SetBytes(&buf32)
+	s.SetBytes(&buf)
 	return &s
 }
 
@@ -123,3 +130,74 @@ func nextPowerOfTwo(v int) int {
 	v++
 	return v
 }
+
+// decodePoint is the checked counterpart of hexToPoint, it rejects malformed
+// hex, wrong lengths and non canonical encodings instead of panicking.
+func decodePoint(h string) (*ristretto.Point, error) {
+	buf, err := hex.DecodeString(h)
+	if err != nil {
+		return nil, err
+	}
+	return pointFromBytes(buf)
+}
+
+func pointFromBytes(buf []byte) (*ristretto.Point, error) {
+	if len(buf) != 32 {
+		return nil, fmt.Errorf("Invalid point length %d", len(buf))
+	}
+	var buf32 [32]byte
+	copy(buf32[:], buf)
+	var p ristretto.Point
+	if !p.SetBytes(&buf32) {
+		return nil, fmt.Errorf("Invalid point %s", hex.EncodeToString(buf))
+	}
+	if !bytes.Equal(p.Bytes(), buf) {
+		return nil, fmt.Errorf("Non canonical point %s", hex.EncodeToString(buf))
+	}
+	return &p, nil
+}
+
+// decodeScalar is the checked counterpart of hexToScalar.
+func decodeScalar(h string) (*ristretto.Scalar, error) {
+	buf, err := hex.DecodeString(h)
+	if err != nil {
+		return nil, err
+	}
+	return scalarFromBytes(buf)
+}
+
+func scalarFromBytes(buf []byte) (*ristretto.Scalar, error) {
+	if len(buf) != 32 {
+		return nil, fmt.Errorf("Invalid scalar length %d", len(buf))
+	}
+	var buf32 [32]byte
+	copy(buf32[:], buf)
+	var s ristretto.Scalar
+	s.SetBytes(&buf32)
+	if !bytes.Equal(s.Bytes(), buf) {
+		return nil, fmt.Errorf("Non canonical scalar %s", hex.EncodeToString(buf))
+	}
+	return &s, nil
+}
+
+// randomScalar is Scalar.Rand reading from rng.
+func randomScalar(rng io.Reader) (*ristretto.Scalar, error) {
+	var buf [64]byte
+	_, err := io.ReadFull(rng, buf[:])
+	if err != nil {
+		return nil, err
+	}
+	var s ristretto.Scalar
+	return s.SetReduced(&buf), nil
+}
+
+// randomPoint is Point.Rand reading from rng.
+func randomPoint(rng io.Reader) (*ristretto.Point, error) {
+	var buf [32]byte
+	_, err := io.ReadFull(rng, buf[:])
+	if err != nil {
+		return nil, err
+	}
+	var p ristretto.Point
+	return p.SetElligator(&buf), nil
+}
@@ -1,7 +1,9 @@
 package api
 
 import (
-	"encoding/hex"
+	"crypto/rand"
+	"fmt"
+	"io"
 
 	"github.com/bwesterb/go-ristretto"
 	"github.com/dchest/blake2b"
@@ -18,32 +20,49 @@ type OutputWithSharedSecret struct {
 	Index        int
 }
 
-/*
-func createOutputWithFogHint(value uint64, recipient *PublicAddress, hint string, index int) (*OutputAndSharedSecret, string, error) {
-}
-*/
-
 func CreateOutput(value uint64, recipient *account.PublicAddress, index int) (*OutputAndSharedSecret, string, error) {
-	var r ristretto.Scalar
-	r.Rand()
+	return CreateOutputWithRNG(value, recipient, index, rand.Reader)
+}
 
-	hint, _, err := CreateFogHint(recipient)
+func CreateOutputWithRNG(value uint64, recipient *account.PublicAddress, index int, rng io.Reader) (*OutputAndSharedSecret, string, error) {
+	hint, _, err := CreateFogHintWithRNG(recipient, rng)
+	if err != nil {
+		return nil, "", err
+	}
+	output, err := createOutputWithFogHint(value, recipient, hint, index, rng)
 	if err != nil {
 		return nil, "", err
 	}
-	target := createOnetimePublicKey(&r, recipient)
-	public := createTxPublicKey(&r, hexToPoint(recipient.SpendPublicKey))
+	return output, output.Output.PublicKey.String(), nil
+}
+
+func createOutputWithFogHint(value uint64, recipient *account.PublicAddress, hint []byte, index int, rng io.Reader) (*OutputAndSharedSecret, error) {
+	view, err := decodePoint(recipient.ViewPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid recipient view public key %s", err)
+	}
+	spend, err := decodePoint(recipient.SpendPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid recipient spend public key %s", err)
+	}
+	r, err := randomScalar(rng)
+	if err != nil {
+		return nil, err
+	}
+
+	target := createOnetimePublicKey(r, view, spend)
+	public := createTxPublicKey(r, spend)
 
-	view := hexToPoint(recipient.ViewPublicKey)
-	secret := createSharedSecret(view, &r)
+	secret := createSharedSecret(view, r)
 	amount, _ := newAmount(value, secret)
 
 	output := &TxOut{
 		Amount:    amount,
-		TargetKey: hex.EncodeToString(target.Bytes()),
-		PublicKey: hex.EncodeToString(public.Bytes()),
-		EFogHint:  hex.EncodeToString(hint),
+		TargetKey: CompressedRistrettoFromPoint(target),
+		PublicKey: CompressedRistrettoFromPoint(public),
+		EFogHint:  EncryptedFogHint(hint),
 	}
+	output.cachePoints()
 
 	return &OutputAndSharedSecret{
 		Output:       output,
@@ -51,7 +70,7 @@ func CreateOutput(value uint64, recipient *account.PublicAddress, index int) (*O
 		Index:        index,
 		Receiver:     recipient,
 		Value:        value,
-	}, hex.EncodeToString(public.Bytes()), nil
+	}, nil
 }
 
 func newAmount(value uint64, secret *ristretto.Point) (*Amount, *ristretto.Scalar) {
@@ -59,16 +78,15 @@ func newAmount(value uint64, secret *ristretto.Point) (*Amount, *ristretto.Scala
 	commitment := NewCommitment(value, blinding)
 	mask := GetValueMask(secret)
 	maskedValue := value ^ mask
+	compressed := CompressedRistrettoFromPoint(commitment)
 	return &Amount{
-		Commitment:  hex.EncodeToString(commitment.Bytes()),
-		MaskedValue: MaskedValue(maskedValue),
+		Commitment:        compressed,
+		MaskedValue:       MaskedValue(maskedValue),
+		decodedCommitment: newDecodedPoint(compressed),
 	}, blinding
 }
 
-func createOnetimePublicKey(private *ristretto.Scalar, recipient *account.PublicAddress) *ristretto.Point {
-	R := hexToPoint(recipient.ViewPublicKey)
-	D := hexToPoint(recipient.SpendPublicKey)
-
+func createOnetimePublicKey(private *ristretto.Scalar, R, D *ristretto.Point) *ristretto.Point {
 	hs := hashToScalar(R, private)
 	var r1, r ristretto.Point
 	var g ristretto.Point
@@ -80,7 +98,14 @@ func createTxPublicKey(private *ristretto.Scalar, spend *ristretto.Point) *ristr
 	return r.ScalarMult(spend, private)
 }
 
-func RecoverOnetimePrivateKey(public, private string) (*ristretto.Scalar, error) {
+// RecoverOnetimePrivateKey is the private key spending txOut, received on
+// the subaddress subaddressIndex of the account private, the hex of its
+// view and spend private keys. It fails when the key doesn't match the
+// target key of txOut, which would only produce an invalid signature.
+func RecoverOnetimePrivateKey(txOut *TxOut, private string, subaddressIndex uint64) (*ristretto.Scalar, error) {
+	if len(private) != 128 {
+		return nil, fmt.Errorf("Invalid private key length %d", len(private))
+	}
 	view := private[:64]
 	spend := private[64:]
 
@@ -89,13 +114,21 @@ func RecoverOnetimePrivateKey(public, private string) (*ristretto.Scalar, error)
 		return nil, err
 	}
 
-	pk := hexToPoint(public)
+	pk, err := txOut.PublicKeyPoint()
+	if err != nil {
+		return nil, err
+	}
 	// `Hs( a * R )`
 	Hs := hashToScalar(pk, account.ViewPrivateKey)
-	d := account.SubaddressSpendPrivateKey(0)
+	d := account.SubaddressSpendPrivateKey(subaddressIndex)
 
 	var x ristretto.Scalar
-	return x.Add(Hs, d), nil
+	x.Add(Hs, d)
+	var target ristretto.Point
+	if CompressedRistrettoFromPoint(target.ScalarMultBase(&x)) != txOut.TargetKey {
+		return nil, fmt.Errorf("Invalid tx out %s, not owned by subaddress %d", txOut.PublicKey, subaddressIndex)
+	}
+	return &x, nil
 }
 
 func ConfirmationNumberFromSecret(secret *ristretto.Point) []byte {
@@ -3,6 +3,7 @@ package api
 import (
 	"errors"
 	"fmt"
+	"io"
 
 	"github.com/bwesterb/go-ristretto"
 )
@@ -50,16 +51,18 @@ type PartyAwaitingBitChallenge struct {
 	SR        []*ristretto.Scalar
 }
 
-func (p *PartyAwaitingPosition) AssignPositionWithRNG(j int) (*PartyAwaitingBitChallenge, *BitCommitment, error) {
+func (p *PartyAwaitingPosition) AssignPositionWithRNG(j int, rng io.Reader) (*PartyAwaitingBitChallenge, *BitCommitment, error) {
 	if p.BPGens.PartyCapacity <= int64(j) {
 		return nil, nil, fmt.Errorf("AssignPositionWithRNG InvalidGeneratorsLength %d, %d", p.BPGens.PartyCapacity, j)
 	}
 	bpShare := p.BPGens.Share(j)
 
-	var aBlinding ristretto.Scalar
-	aBlinding.Rand()
+	aBlinding, err := randomScalar(rng)
+	if err != nil {
+		return nil, nil, err
+	}
 	var A ristretto.Point
-	A.ScalarMult(p.PCGens.BBlinding, &aBlinding)
+	A.ScalarMult(p.PCGens.BBlinding, aBlinding)
 
 	// If v_i = 0, we add a_L[i] * G[i] + a_R[i] * H[i] = - H[i]
 	// If v_i = 1, we add a_L[i] * G[i] + a_R[i] * H[i] =   G[i]
@@ -77,19 +80,26 @@ func (p *PartyAwaitingPosition) AssignPositionWithRNG(j int) (*PartyAwaitingBitC
 		A.Add(&A, &point)
 	}
 
-	var sBlinding ristretto.Scalar
-	sBlinding.Rand()
+	sBlinding, err := randomScalar(rng)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	sL := make([]*ristretto.Scalar, p.N)
 	sR := make([]*ristretto.Scalar, p.N)
 	for i := 0; i < int(p.N); i++ {
-		var s1, s2 ristretto.Scalar
-		sL[i] = s1.Rand()
-		sR[i] = s2.Rand()
+		sL[i], err = randomScalar(rng)
+		if err != nil {
+			return nil, nil, err
+		}
+		sR[i], err = randomScalar(rng)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
 	// Compute S = <s_L, G> + <s_R, H> + s_blinding * B_blinding
-	s1 := append([]*ristretto.Scalar{&sBlinding}, sL...)
+	s1 := append([]*ristretto.Scalar{sBlinding}, sL...)
 	s1 = append(s1, sR...)
 	s2 := append([]*ristretto.Point{p.PCGens.BBlinding}, Gs...)
 	s2 = append(s2, Hs...)
@@ -107,15 +117,15 @@ func (p *PartyAwaitingPosition) AssignPositionWithRNG(j int) (*PartyAwaitingBitC
 		VBlinding: p.VBlinding,
 		PCGens:    p.PCGens,
 		J:         j,
-		ABlinding: &aBlinding,
-		SBlinding: &sBlinding,
+		ABlinding: aBlinding,
+		SBlinding: sBlinding,
 		SL:        sL,
 		SR:        sR,
 	}
 	return nextState, bitCommitment, nil
 }
 
-func (p *PartyAwaitingBitChallenge) ApplyChallengeWithRNG(vc *BitChallenge) (*PartyAwaitingPolyChallenge, *PolyCommitment) {
+func (p *PartyAwaitingBitChallenge) ApplyChallengeWithRNG(vc *BitChallenge, rng io.Reader) (*PartyAwaitingPolyChallenge, *PolyCommitment, error) {
 	OffsetY := ScalarExpVartime(vc.Y, uint64(int64(p.J)*p.N))
 	OffsetZ := ScalarExpVartime(vc.Z, uint64(p.J))
 
@@ -152,12 +162,17 @@ func (p *PartyAwaitingBitChallenge) ApplyChallengeWithRNG(vc *BitChallenge) (*Pa
 
 	tPoly := LPoly.InnerProduct(RPoly)
 
-	var t1blinding, t2blinding ristretto.Scalar
-	t1blinding.Rand()
-	t2blinding.Rand()
+	t1blinding, err := randomScalar(rng)
+	if err != nil {
+		return nil, nil, err
+	}
+	t2blinding, err := randomScalar(rng)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	T1 := p.PCGens.Commit(tPoly.B, &t1blinding)
-	T2 := p.PCGens.Commit(tPoly.C, &t2blinding)
+	T1 := p.PCGens.Commit(tPoly.B, t1blinding)
+	T2 := p.PCGens.Commit(tPoly.C, t2blinding)
 
 	poly_commitment := &PolyCommitment{
 		T1j: T1,
@@ -169,13 +184,13 @@ func (p *PartyAwaitingBitChallenge) ApplyChallengeWithRNG(vc *BitChallenge) (*Pa
 		LPoly:      LPoly,
 		RPoly:      RPoly,
 		TPoly:      tPoly,
-		T1Blinding: &t1blinding,
-		T2Blinding: &t2blinding,
+		T1Blinding: t1blinding,
+		T2Blinding: t2blinding,
 		VBlinding:  p.VBlinding,
 		ABlinding:  p.ABlinding,
 		SBlinding:  p.SBlinding,
 	}
-	return papc, poly_commitment
+	return papc, poly_commitment, nil
 }
 
 func innerProduct(a []*ristretto.Scalar, b []*ristretto.Scalar) *ristretto.Scalar {
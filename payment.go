@@ -27,7 +27,7 @@ func keyImage(private *ristretto.Scalar) *ristretto.Point {
 }
 
 func GetValueWithBlinding(output *TxOut, viewPrivate *ristretto.Scalar) (uint64, *ristretto.Scalar) {
-	secret := createSharedSecret(hexToPoint(output.PublicKey), viewPrivate)
+	secret := createSharedSecret(bytesToPoint(output.PublicKey), viewPrivate)
 
 	mask := GetValueMask(secret)
 	maskedValue := uint64(output.Amount.MaskedValue)
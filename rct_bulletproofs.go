@@ -1,8 +1,11 @@
 package api
 
 import (
+	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 
 	"github.com/bwesterb/go-ristretto"
 	"github.com/gtank/merlin"
@@ -14,10 +17,17 @@ type PseudoOutputValuesAndBlindings struct {
 }
 
 func SignRctBulletproofs(message []byte, inputs []*InputCredential, fee uint64, outputWithSharedSecrets []*OutputAndSharedSecret) (*SignatureRctBulletproofs, error) {
+	return SignRctBulletproofsWithRNG(message, inputs, fee, outputWithSharedSecrets, rand.Reader)
+}
+
+func SignRctBulletproofsWithRNG(message []byte, inputs []*InputCredential, fee uint64, outputWithSharedSecrets []*OutputAndSharedSecret, rng io.Reader) (*SignatureRctBulletproofs, error) {
 	pseudoOutputBlindings := make([]*ristretto.Scalar, len(inputs)-1)
 	for i := 0; i < len(inputs)-1; i++ {
-		var r ristretto.Scalar
-		pseudoOutputBlindings[i] = r.Rand()
+		r, err := randomScalar(rng)
+		if err != nil {
+			return nil, err
+		}
+		pseudoOutputBlindings[i] = r
 	}
 
 	var sumOfOutputBlindings ristretto.Scalar
@@ -63,7 +73,7 @@ func SignRctBulletproofs(message []byte, inputs []*InputCredential, fee uint64,
 
 	bpGens := NewBulletproofGens(64, 64)
 	pcGens := NewPedersenGens()
-	range_proof, commitments, err := GenerateRangeProofs(bpGens, pcGens, values, blindings)
+	range_proof, commitments, err := GenerateRangeProofsWithRNG(bpGens, pcGens, values, blindings, rng)
 	if err != nil {
 		return nil, err
 	}
@@ -87,7 +97,7 @@ func SignRctBulletproofs(message []byte, inputs []*InputCredential, fee uint64,
 	var ring_signatures []*RingMLSAG
 	for i, input := range inputs {
 		value, blinding := GetValueWithBlinding(inputs[i].Ring[input.RealIndex], inputs[i].ViewPrivateKey)
-		ring_signature, err := signRing(extended_message, inputs[i].Ring, inputs[i].RealIndex, inputs[i].OnetimePrivateKey, value, blinding, pseudoOutputBlindings[i])
+		ring_signature, err := signRing(extended_message, inputs[i].Ring, inputs[i].RealIndex, inputs[i].OnetimePrivateKey, value, blinding, pseudoOutputBlindings[i], rng)
 		if err != nil {
 			return nil, err
 		}
@@ -125,13 +135,17 @@ func (p *RangeProof) ToBytes() []byte {
 }
 
 func GenerateRangeProofs(bpGens *BulletproofGens, pcGens *PedersenGens, values []uint64, blindings []*ristretto.Scalar) (*RangeProof, []*ristretto.Point, error) {
+	return GenerateRangeProofsWithRNG(bpGens, pcGens, values, blindings, rand.Reader)
+}
+
+func GenerateRangeProofsWithRNG(bpGens *BulletproofGens, pcGens *PedersenGens, values []uint64, blindings []*ristretto.Scalar, rng io.Reader) (*RangeProof, []*ristretto.Point, error) {
 	valuesPadded := resizeUint64ToPow2(values)
 	blindingsPadded := resizeScalarToPow2(blindings)
 
 	initial := InitialTranscript(BULLETPROOF_DOMAIN_TAG)
 	transcript := InitialTranscript(BULLETPROOF_DOMAIN_TAG)
 
-	return ProveMultipleWithRNG(bpGens, pcGens, initial, transcript, valuesPadded, blindingsPadded, 64)
+	return ProveMultipleWithRNG(bpGens, pcGens, initial, transcript, valuesPadded, blindingsPadded, 64, rng)
 }
 
 // n = 64
@@ -143,6 +157,7 @@ func ProveMultipleWithRNG(
 	values []uint64,
 	blindings []*ristretto.Scalar,
 	n int64,
+	rng io.Reader,
 ) (*RangeProof, []*ristretto.Point, error) {
 	if len(values) != len(blindings) {
 		return nil, nil, fmt.Errorf("ProveMultipleWithRNG WrongNumBlindingFactors %d, %d", len(values), len(blindings))
@@ -159,7 +174,7 @@ func ProveMultipleWithRNG(
 	partiesA := make([]*PartyAwaitingBitChallenge, len(parties))
 	bitCommitments := make([]*BitCommitment, len(parties))
 	for j := range parties {
-		partiesA[j], bitCommitments[j], err = parties[j].AssignPositionWithRNG(j)
+		partiesA[j], bitCommitments[j], err = parties[j].AssignPositionWithRNG(j, rng)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -177,7 +192,10 @@ func ProveMultipleWithRNG(
 	partiesB := make([]*PartyAwaitingPolyChallenge, len(partiesA))
 	polyCommitments := make([]*PolyCommitment, len(partiesA))
 	for i := range partiesA {
-		partiesB[i], polyCommitments[i] = partiesA[i].ApplyChallengeWithRNG(bitChallenge)
+		partiesB[i], polyCommitments[i], err = partiesA[i].ApplyChallengeWithRNG(bitChallenge, rng)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
 	dealer3, polyChallenge := dealer2.ReceivePolyCommitments(polyCommitments)
@@ -190,3 +208,374 @@ func ProveMultipleWithRNG(
 	proof := dealer3.AssembleShares(proofShares)
 	return proof, valueCommitments, nil
 }
+
+// RangeProofFromBytes parses the encoding of RangeProof.ToBytes, it rejects
+// any point or scalar that is not canonically encoded, so the result always
+// encodes back to buf.
+func RangeProofFromBytes(buf []byte) (*RangeProof, error) {
+	if len(buf)%32 != 0 || len(buf) < 7*32 {
+		return nil, fmt.Errorf("Invalid range proof length %d", len(buf))
+	}
+
+	points := make([]*ristretto.Point, 4)
+	for i := range points {
+		p, err := pointFromBytes(buf[32*i : 32*i+32])
+		if err != nil {
+			return nil, err
+		}
+		points[i] = p
+	}
+	scalars := make([]*ristretto.Scalar, 3)
+	for i := range scalars {
+		s, err := scalarFromBytes(buf[128+32*i : 160+32*i])
+		if err != nil {
+			return nil, err
+		}
+		scalars[i] = s
+	}
+	ippProof, err := InnerProductProofFromBytes(buf[7*32:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &RangeProof{
+		A:          points[0],
+		S:          points[1],
+		T1:         points[2],
+		T2:         points[3],
+		TX:         scalars[0],
+		TXBlinding: scalars[1],
+		EBlinding:  scalars[2],
+		IPPProof:   ippProof,
+	}, nil
+}
+
+func (s *SignatureRctBulletproofs) DecodeRangeProof() (*RangeProof, error) {
+	buf, err := hex.DecodeString(s.RangeProofs)
+	if err != nil {
+		return nil, err
+	}
+	return RangeProofFromBytes(buf)
+}
+
+var ErrProofVerification = errors.New("Proof VerificationError")
+
+// rangeProofCheck holds the terms of a range proof verification equation,
+// the proof is valid when the multiscalar multiplication of all of them is
+// the identity. Terms on the shared generators are kept apart so several
+// checks can be merged into a single multiplication.
+type rangeProofCheck struct {
+	Scalars   []*ristretto.Scalar
+	Points    []*ristretto.Point
+	BBlinding *ristretto.Scalar
+	B         *ristretto.Scalar
+	G         []*ristretto.Scalar
+	H         []*ristretto.Scalar
+}
+
+// https://github.com/dalek-cryptography/bulletproofs/blob/main/src/range_proof/mod.rs
+// verify_multiple_with_rng
+func (p *RangeProof) Verify(BPGens *BulletproofGens, PCGens *PedersenGens, transcript *merlin.Transcript, commitments []*ristretto.Point, n int64) error {
+	check, err := p.verificationCheck(BPGens, transcript, commitments, n)
+	if err != nil {
+		return err
+	}
+	return verifyRangeProofChecks(BPGens, PCGens, n, []*rangeProofCheck{check})
+}
+
+// BatchVerifyRangeProofs verifies several aggregated range proofs, all of
+// bitsize n, at once. transcripts and commitments are given per proof.
+func BatchVerifyRangeProofs(BPGens *BulletproofGens, PCGens *PedersenGens, proofs []*RangeProof, transcripts []*merlin.Transcript, commitments [][]*ristretto.Point, n int64) error {
+	if len(proofs) != len(transcripts) || len(proofs) != len(commitments) {
+		return fmt.Errorf("BatchVerifyRangeProofs WrongNumProofs %d, %d, %d", len(proofs), len(transcripts), len(commitments))
+	}
+	checks := make([]*rangeProofCheck, len(proofs))
+	for i := range proofs {
+		check, err := proofs[i].verificationCheck(BPGens, transcripts[i], commitments[i], n)
+		if err != nil {
+			return err
+		}
+		checks[i] = check
+	}
+	return verifyRangeProofChecks(BPGens, PCGens, n, checks)
+}
+
+func (p *RangeProof) verificationCheck(BPGens *BulletproofGens, transcript *merlin.Transcript, commitments []*ristretto.Point, n int64) (*rangeProofCheck, error) {
+	m := int64(len(commitments))
+	switch n {
+	case 8, 16, 32, 64:
+	default:
+		return nil, fmt.Errorf("RangeProof Verify InvalidBitsize n: %d", n)
+	}
+	if BPGens.GensCapacity < n {
+		return nil, fmt.Errorf("RangeProof Verify InvalidGeneratorsLength GensCapacity %d, n %d", BPGens.GensCapacity, n)
+	}
+	if BPGens.PartyCapacity < m {
+		return nil, fmt.Errorf("RangeProof Verify InvalidGeneratorsLength PartyCapacity %d, m %d", BPGens.PartyCapacity, m)
+	}
+
+	var identity ristretto.Point
+	identity.SetZero()
+	for _, point := range append([]*ristretto.Point{p.A, p.S, p.T1, p.T2}, append(p.IPPProof.LVec, p.IPPProof.RVec...)...) {
+		if point.Equals(&identity) {
+			return nil, ErrProofVerification
+		}
+	}
+
+	RangeproofDomainSep(n, m, transcript)
+	for i := range commitments {
+		AppendPoint("V", commitments[i], transcript)
+	}
+	AppendPoint("A", p.A, transcript)
+	AppendPoint("S", p.S, transcript)
+	y := ChallengeScalar("y", transcript)
+	z := ChallengeScalar("z", transcript)
+	var zz, minusZ ristretto.Scalar
+	zz.Mul(z, z)
+	minusZ.Neg(z)
+
+	AppendPoint("T_1", p.T1, transcript)
+	AppendPoint("T_2", p.T2, transcript)
+	x := ChallengeScalar("x", transcript)
+
+	AppendScalar("t_x", p.TX, transcript)
+	AppendScalar("t_x_blinding", p.TXBlinding, transcript)
+	AppendScalar("e_blinding", p.EBlinding, transcript)
+	w := ChallengeScalar("w", transcript)
+
+	// Combines the inner product and the t(x) checks
+	var c ristretto.Scalar
+	c.Rand()
+
+	size := int(n * m)
+	xSq, xInvSq, s, err := p.IPPProof.verificationScalars(size, transcript)
+	if err != nil {
+		return nil, err
+	}
+	a := p.IPPProof.A
+	b := p.IPPProof.B
+
+	// z^0 * 2^n || z^1 * 2^n || ... || z^(m-1) * 2^n
+	concatZAnd2 := make([]*ristretto.Scalar, 0, size)
+	expZ := NewScalarExp(z)
+	for j := int64(0); j < m; j++ {
+		zj := expZ.Next()
+		var exp2 ristretto.Scalar
+		exp2.SetOne()
+		for i := int64(0); i < n; i++ {
+			var r ristretto.Scalar
+			concatZAnd2 = append(concatZAnd2, r.Mul(&exp2, zj))
+			exp2.Add(&exp2, &exp2)
+		}
+	}
+
+	var yInv ristretto.Scalar
+	yInv.Inverse(y)
+	expYInv := NewScalarExp(&yInv)
+	g := make([]*ristretto.Scalar, size)
+	h := make([]*ristretto.Scalar, size)
+	for i := 0; i < size; i++ {
+		var gi, t ristretto.Scalar
+		g[i] = gi.Sub(&minusZ, t.Mul(a, s[i]))
+
+		var sInv, hi, u ristretto.Scalar
+		sInv.Set(s[size-1-i])
+		u.Mul(&zz, concatZAnd2[i])
+		u.Sub(&u, sInv.Mul(b, &sInv))
+		hi.Mul(expYInv.Next(), &u)
+		h[i] = hi.Add(z, &hi)
+	}
+
+	var cx, cxx, ab, basepoint, blinding, t ristretto.Scalar
+	cx.Mul(&c, x)
+	cxx.Mul(&cx, x)
+	ab.Mul(a, b)
+	basepoint.Sub(p.TX, &ab)
+	basepoint.Mul(w, &basepoint)
+	t.Sub(delta(n, m, y, z), p.TX)
+	basepoint.Add(&basepoint, t.Mul(&c, &t))
+	blinding.Mul(&c, p.TXBlinding)
+	blinding.Add(p.EBlinding, &blinding)
+	blinding.Neg(&blinding)
+
+	var one ristretto.Scalar
+	one.SetOne()
+	scalars := []*ristretto.Scalar{&one, x, &cx, &cxx}
+	scalars = append(scalars, xSq...)
+	scalars = append(scalars, xInvSq...)
+	expZ = NewScalarExp(z)
+	for j := int64(0); j < m; j++ {
+		var r ristretto.Scalar
+		r.Mul(&c, &zz)
+		scalars = append(scalars, r.Mul(&r, expZ.Next()))
+	}
+
+	points := []*ristretto.Point{p.A, p.S, p.T1, p.T2}
+	points = append(points, p.IPPProof.LVec...)
+	points = append(points, p.IPPProof.RVec...)
+	points = append(points, commitments...)
+
+	return &rangeProofCheck{
+		Scalars:   scalars,
+		Points:    points,
+		BBlinding: &blinding,
+		B:         &basepoint,
+		G:         g,
+		H:         h,
+	}, nil
+}
+
+// verifyRangeProofChecks weights every check but the first with a random
+// scalar, so that the sum is the identity only if every check is.
+func verifyRangeProofChecks(BPGens *BulletproofGens, PCGens *PedersenGens, n int64, checks []*rangeProofCheck) error {
+	var size int
+	for _, check := range checks {
+		if len(check.G) > size {
+			size = len(check.G)
+		}
+	}
+
+	var bBlinding, b ristretto.Scalar
+	bBlinding.SetZero()
+	b.SetZero()
+	g := make([]*ristretto.Scalar, size)
+	h := make([]*ristretto.Scalar, size)
+	for i := 0; i < size; i++ {
+		var gi, hi ristretto.Scalar
+		g[i] = gi.SetZero()
+		h[i] = hi.SetZero()
+	}
+
+	var scalars []*ristretto.Scalar
+	var points []*ristretto.Point
+	for k, check := range checks {
+		var weight ristretto.Scalar
+		weight.SetOne()
+		if k > 0 {
+			weight.Rand()
+		}
+		for i := range check.Scalars {
+			var r ristretto.Scalar
+			scalars = append(scalars, r.Mul(&weight, check.Scalars[i]))
+		}
+		points = append(points, check.Points...)
+
+		var t ristretto.Scalar
+		bBlinding.Add(&bBlinding, t.Mul(&weight, check.BBlinding))
+		b.Add(&b, t.Mul(&weight, check.B))
+		for i := range check.G {
+			g[i].Add(g[i], t.Mul(&weight, check.G[i]))
+			h[i].Add(h[i], t.Mul(&weight, check.H[i]))
+		}
+	}
+
+	scalars = append(scalars, &bBlinding, &b)
+	scalars = append(scalars, g...)
+	scalars = append(scalars, h...)
+	points = append(points, PCGens.BBlinding, PCGens.B)
+	m := (int64(size) + n - 1) / n
+	G := BPGens.G(n, m)
+	for i := 0; i < size; i++ {
+		points = append(points, G.Next())
+	}
+	H := BPGens.H(n, m)
+	for i := 0; i < size; i++ {
+		points = append(points, H.Next())
+	}
+
+	var identity ristretto.Point
+	if !vartimeMultiscalarMul(scalars, points).Equals(identity.SetZero()) {
+		return ErrProofVerification
+	}
+	return nil
+}
+
+// (z - z^2) * <1, y^(n*m)> - z^3 * <1, 2^n> * <1, z^m>
+func delta(n, m int64, y, z *ristretto.Scalar) *ristretto.Scalar {
+	var two ristretto.Scalar
+	two.SetOne()
+	two.Add(&two, &two)
+
+	sumY := sumOfPowers(y, n*m)
+	sum2 := sumOfPowers(&two, n)
+	sumZ := sumOfPowers(z, m)
+
+	var zz, zzz, r, t ristretto.Scalar
+	zz.Mul(z, z)
+	zzz.Mul(&zz, z)
+	r.Sub(z, &zz)
+	r.Mul(&r, sumY)
+	t.Mul(&zzz, sum2)
+	t.Mul(&t, sumZ)
+	return r.Sub(&r, &t)
+}
+
+// 1 + x + x^2 + ... + x^(n-1)
+func sumOfPowers(x *ristretto.Scalar, n int64) *ristretto.Scalar {
+	var sum ristretto.Scalar
+	sum.SetZero()
+	exp := NewScalarExp(x)
+	for i := int64(0); i < n; i++ {
+		sum.Add(&sum, exp.Next())
+	}
+	return &sum
+}
+
+// https://github.com/mobilecoinfoundation/mobilecoin/blob/master/transaction/core/src/ring_signature/rct_bulletproofs.rs
+// verify
+func verifyRctBulletproofs(message []byte, rings [][]*TxOut, outputCommitments []*ristretto.Point, fee uint64, signature *SignatureRctBulletproofs) error {
+	if len(rings) != len(signature.RingSignatures) || len(rings) != len(signature.PseudoOutputCommitments) {
+		return validationError(InvalidTransactionSignature, "rings %d, signatures %d, pseudo outputs %d", len(rings), len(signature.RingSignatures), len(signature.PseudoOutputCommitments))
+	}
+
+	pseudoOutputCommitments := make([]*ristretto.Point, len(signature.PseudoOutputCommitments))
+	for i := range signature.PseudoOutputCommitments {
+		p, err := decodePoint(signature.PseudoOutputCommitments[i])
+		if err != nil {
+			return validationError(InvalidTransactionSignature, "pseudo output %d %s", i, err)
+		}
+		pseudoOutputCommitments[i] = p
+	}
+
+	rangeProof, err := signature.DecodeRangeProof()
+	if err != nil {
+		return validationError(InvalidRangeProof, "%s", err)
+	}
+	commitments := append([]*ristretto.Point{}, pseudoOutputCommitments...)
+	commitments = append(commitments, outputCommitments...)
+	commitments = resizePointToPow2(commitments)
+	transcript := InitialTranscript(BULLETPROOF_DOMAIN_TAG)
+	err = rangeProof.Verify(NewBulletproofGens(64, 64), NewPedersenGens(), transcript, commitments, 64)
+	if err != nil {
+		return validationError(InvalidRangeProof, "%s", err)
+	}
+
+	extendedMessage := make([]byte, 0)
+	extendedMessage = append(extendedMessage, message...)
+	for i := range pseudoOutputCommitments {
+		extendedMessage = append(extendedMessage, pseudoOutputCommitments[i].Bytes()...)
+	}
+	extendedMessage = append(extendedMessage, rangeProof.ToBytes()...)
+
+	for i := range rings {
+		err := VerifyRingMLSAG(extendedMessage, rings[i], pseudoOutputCommitments[i], signature.RingSignatures[i])
+		if err != nil {
+			return validationError(InvalidTransactionSignature, "ring %d %s", i, err)
+		}
+	}
+
+	var difference ristretto.Point
+	difference.SetZero()
+	for i := range outputCommitments {
+		difference.Add(&difference, outputCommitments[i])
+	}
+	difference.Add(&difference, NewCommitment(fee, new(ristretto.Scalar).SetZero()))
+	for i := range pseudoOutputCommitments {
+		difference.Sub(&difference, pseudoOutputCommitments[i])
+	}
+	var identity ristretto.Point
+	if !difference.Equals(identity.SetZero()) {
+		return validationError(ValueNotConserved, "")
+	}
+	return nil
+}
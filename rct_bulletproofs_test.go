@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/bwesterb/go-ristretto"
+	"github.com/gtank/merlin"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -49,3 +50,162 @@ func testGenerateRangeProofs(t *testing.T) {
 
 	log.Println("proof:::", hex.EncodeToString(proof.ToBytes()))
 }
+
+func TestRangeProofVerify(t *testing.T) {
+	assert := assert.New(t)
+
+	bpGens := NewBulletproofGens(64, 64)
+	pcGens := NewPedersenGens()
+	values := []uint64{1, 3, 4, 5}
+	blindings := make([]*ristretto.Scalar, len(values))
+	for i := range blindings {
+		var b ristretto.Scalar
+		blindings[i] = b.Rand()
+	}
+	proof, commitments, err := GenerateRangeProofs(bpGens, pcGens, values, blindings)
+	assert.Nil(err)
+	assert.Nil(proof.Verify(bpGens, pcGens, InitialTranscript(BULLETPROOF_DOMAIN_TAG), commitments, 64))
+
+	tampered := append([]*ristretto.Point{}, commitments...)
+	tampered[0], tampered[1] = commitments[1], commitments[0]
+	assert.Equal(ErrProofVerification, proof.Verify(bpGens, pcGens, InitialTranscript(BULLETPROOF_DOMAIN_TAG), tampered, 64))
+	assert.NotNil(proof.Verify(bpGens, pcGens, InitialTranscript(BULLETPROOF_DOMAIN_TAG), commitments[:2], 64))
+	assert.NotNil(proof.Verify(bpGens, pcGens, InitialTranscript(BULLETPROOF_DOMAIN_TAG), commitments, 7))
+
+	other, otherCommitments, err := GenerateRangeProofs(bpGens, pcGens, []uint64{7}, blindings[:1])
+	assert.Nil(err)
+	assert.Nil(other.Verify(bpGens, pcGens, InitialTranscript(BULLETPROOF_DOMAIN_TAG), otherCommitments, 64))
+
+	proofs := []*RangeProof{proof, other}
+	transcripts := []*merlin.Transcript{InitialTranscript(BULLETPROOF_DOMAIN_TAG), InitialTranscript(BULLETPROOF_DOMAIN_TAG)}
+	err = BatchVerifyRangeProofs(bpGens, pcGens, proofs, transcripts, [][]*ristretto.Point{commitments, otherCommitments}, 64)
+	assert.Nil(err)
+
+	transcripts = []*merlin.Transcript{InitialTranscript(BULLETPROOF_DOMAIN_TAG), InitialTranscript(BULLETPROOF_DOMAIN_TAG)}
+	err = BatchVerifyRangeProofs(bpGens, pcGens, proofs, transcripts, [][]*ristretto.Point{tampered, otherCommitments}, 64)
+	assert.Equal(ErrProofVerification, err)
+
+	err = BatchVerifyRangeProofs(bpGens, pcGens, proofs, transcripts[:1], [][]*ristretto.Point{commitments, otherCommitments}, 64)
+	assert.NotNil(err)
+}
+
+func TestInnerProductProofVerify(t *testing.T) {
+	assert := assert.New(t)
+
+	n := 8
+	bpGens := NewBulletproofGens(int64(n), 1)
+	G := bpGens.Share(0).G(int64(n))
+	H := bpGens.Share(0).H(int64(n))
+	Q := NewPedersenGens().B
+
+	var y, yInv ristretto.Scalar
+	y.Rand()
+	yInv.Inverse(&y)
+	expYInv := NewScalarExp(&yInv)
+	a := make([]*ristretto.Scalar, n)
+	b := make([]*ristretto.Scalar, n)
+	gFactors := make([]*ristretto.Scalar, n)
+	hFactors := make([]*ristretto.Scalar, n)
+	for i := 0; i < n; i++ {
+		var ai, bi, gi ristretto.Scalar
+		a[i] = ai.Rand()
+		b[i] = bi.Rand()
+		gFactors[i] = gi.SetOne()
+		hFactors[i] = expYInv.Next()
+	}
+
+	// P = <a, G> + <b * y^-i, H> + <a, b> Q
+	var scalars []*ristretto.Scalar
+	var points []*ristretto.Point
+	for i := 0; i < n; i++ {
+		var bi ristretto.Scalar
+		scalars = append(scalars, a[i], bi.Mul(b[i], hFactors[i]))
+		points = append(points, G[i], H[i])
+	}
+	scalars = append(scalars, innerProduct(a, b))
+	points = append(points, Q)
+	P := vartimeMultiscalarMul(scalars, points)
+
+	proof := CreateInnerProductProof(merlin.NewTranscript("innerproducttest"), Q, gFactors, hFactors,
+		append([]*ristretto.Point{}, G...), append([]*ristretto.Point{}, H...),
+		append([]*ristretto.Scalar{}, a...), append([]*ristretto.Scalar{}, b...))
+	assert.Len(proof.LVec, 3)
+
+	err := proof.Verify(n, merlin.NewTranscript("innerproducttest"), gFactors, hFactors, P, Q, G, H)
+	assert.Nil(err)
+	err = proof.Verify(n, merlin.NewTranscript("other"), gFactors, hFactors, P, Q, G, H)
+	assert.Equal(ErrProofVerification, err)
+	err = proof.Verify(n, merlin.NewTranscript("innerproducttest"), gFactors, hFactors, Q, Q, G, H)
+	assert.Equal(ErrProofVerification, err)
+	err = proof.Verify(4, merlin.NewTranscript("innerproducttest"), gFactors[:4], hFactors[:4], P, Q, G[:4], H[:4])
+	assert.NotNil(err)
+}
+
+func TestRangeProofFromBytes(t *testing.T) {
+	assert := assert.New(t)
+
+	bpGens := NewBulletproofGens(64, 64)
+	pcGens := NewPedersenGens()
+	var blinding ristretto.Scalar
+	blinding.Rand()
+	proof, commitments, err := GenerateRangeProofs(bpGens, pcGens, []uint64{10, 20}, []*ristretto.Scalar{&blinding, &blinding})
+	assert.Nil(err)
+
+	buf := proof.ToBytes()
+	assert.Len(buf, (7+2*7+2)*32)
+	decoded, err := RangeProofFromBytes(buf)
+	assert.Nil(err)
+	assert.Equal(buf, decoded.ToBytes())
+	assert.Nil(decoded.Verify(bpGens, pcGens, InitialTranscript(BULLETPROOF_DOMAIN_TAG), commitments, 64))
+
+	ipp, err := InnerProductProofFromBytes(proof.IPPProof.ToBytes())
+	assert.Nil(err)
+	assert.Equal(proof.IPPProof.ToBytes(), ipp.ToBytes())
+
+	signature := &SignatureRctBulletproofs{RangeProofs: hex.EncodeToString(buf)}
+	decoded, err = signature.DecodeRangeProof()
+	assert.Nil(err)
+	assert.Equal(buf, decoded.ToBytes())
+	signature.RangeProofs = "zz"
+	_, err = signature.DecodeRangeProof()
+	assert.NotNil(err)
+
+	_, err = RangeProofFromBytes(buf[:len(buf)-1])
+	assert.NotNil(err)
+	_, err = RangeProofFromBytes(buf[:len(buf)-32])
+	assert.NotNil(err)
+	_, err = RangeProofFromBytes(buf[:7*32])
+	assert.NotNil(err)
+	_, err = InnerProductProofFromBytes(buf[:32])
+	assert.NotNil(err)
+
+	// A is not a valid ristretto encoding
+	invalid := append([]byte{}, buf...)
+	for i := 0; i < 32; i++ {
+		invalid[i] = 0xff
+	}
+	_, err = RangeProofFromBytes(invalid)
+	assert.NotNil(err)
+
+	// t_x + l, l the order of the group, reduces to the same scalar
+	l, _ := hex.DecodeString("edd3f55c1a631258d69cf7a2def9de1400000000000000000000000000000010")
+	invalid = append([]byte{}, buf...)
+	var carry int
+	for i := 0; i < 32; i++ {
+		v := int(invalid[4*32+i]) + int(l[i]) + carry
+		invalid[4*32+i] = byte(v)
+		carry = v >> 8
+	}
+	if carry == 0 {
+		_, err = RangeProofFromBytes(invalid)
+		assert.NotNil(err)
+	}
+
+	// the last scalar of the inner product proof
+	invalid = append([]byte{}, buf...)
+	copy(invalid[len(invalid)-32:], l)
+	_, err = RangeProofFromBytes(invalid)
+	assert.NotNil(err)
+	_, err = InnerProductProofFromBytes(invalid[7*32:])
+	assert.NotNil(err)
+}
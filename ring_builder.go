@@ -0,0 +1,279 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+const (
+	// DEFAULT_RING_MAX_ATTEMPTS is the number of samples drawn for a ring
+	// when RingBuilder.MaxAttempts is 0.
+	DEFAULT_RING_MAX_ATTEMPTS = RING_SIZE * 100
+
+	// AGE_DISTRIBUTION_MAX_SAMPLES bounds the ages drawn by an
+	// AgeDistribution before it gives up on a mean age too large for the
+	// ledger.
+	AGE_DISTRIBUTION_MAX_SAMPLES = 1000
+)
+
+var (
+	ErrInsufficientTxOuts = errors.New("Insufficient tx outs for a ring")
+	ErrRingTooShort       = errors.New("Ring too short")
+)
+
+// TxOutSource gives access to the ledger tx outs by their global index.
+type TxOutSource interface {
+	NumTxOuts() (uint64, error)
+	GetTxOuts(indices []uint64) ([]*TxOut, error)
+	GetMembershipProofs(indices []uint64) ([]*TxOutMembershipProof, error)
+}
+
+// DecoyDistribution picks the global index of a decoy among numTxOuts.
+type DecoyDistribution interface {
+	Sample(numTxOuts uint64) (uint64, error)
+}
+
+// UniformDistribution picks any tx out with the same probability, Rand is
+// crypto/rand when nil.
+type UniformDistribution struct {
+	Rand io.Reader
+}
+
+func (d UniformDistribution) Sample(numTxOuts uint64) (uint64, error) {
+	if numTxOuts == 0 {
+		return 0, ErrInsufficientTxOuts
+	}
+	f, err := randomFloat(d.Rand)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(f * float64(numTxOuts)), nil
+}
+
+// AgeDistribution favors recent tx outs, the age of a decoy, counted in tx
+// outs from the newest one, follows an exponential distribution of mean
+// MeanAge, which matches how real outputs are usually spent soon after
+// they are received. Rand is crypto/rand when nil.
+type AgeDistribution struct {
+	MeanAge float64
+	Rand    io.Reader
+}
+
+func (d *AgeDistribution) Sample(numTxOuts uint64) (uint64, error) {
+	if numTxOuts == 0 {
+		return 0, ErrInsufficientTxOuts
+	}
+	if !(d.MeanAge > 0) || math.IsInf(d.MeanAge, 0) {
+		return 0, fmt.Errorf("Invalid mean age %f", d.MeanAge)
+	}
+	for i := 0; i < AGE_DISTRIBUTION_MAX_SAMPLES; i++ {
+		f, err := randomFloat(d.Rand)
+		if err != nil {
+			return 0, err
+		}
+		age := -math.Log(1-f) * d.MeanAge
+		if age < float64(numTxOuts) {
+			return numTxOuts - 1 - uint64(age), nil
+		}
+	}
+	return 0, fmt.Errorf("Invalid mean age %f for %d tx outs", d.MeanAge, numTxOuts)
+}
+
+// randomFloat returns a uniform float64 in [0, 1).
+func randomFloat(rng io.Reader) (float64, error) {
+	if rng == nil {
+		rng = rand.Reader
+	}
+	var buf [8]byte
+	_, err := io.ReadFull(rng, buf[:])
+	if err != nil {
+		return 0, err
+	}
+	return float64(binary.LittleEndian.Uint64(buf[:])>>11) / (1 << 53), nil
+}
+
+type RingBuilder struct {
+	Source       TxOutSource
+	Distribution DecoyDistribution
+	// MaxAttempts bounds the number of samples drawn for a ring,
+	// DEFAULT_RING_MAX_ATTEMPTS when 0.
+	MaxAttempts int
+}
+
+// NewRingBuilder samples decoys uniformly when distribution is nil.
+func NewRingBuilder(source TxOutSource, distribution DecoyDistribution) *RingBuilder {
+	if distribution == nil {
+		distribution = UniformDistribution{}
+	}
+	return &RingBuilder{
+		Source:       source,
+		Distribution: distribution,
+		MaxAttempts:  DEFAULT_RING_MAX_ATTEMPTS,
+	}
+}
+
+// BuildRing returns the tx out at realIndex and RING_SIZE-1 decoys with
+// their membership proofs, sorted by public key.
+func (rb *RingBuilder) BuildRing(realIndex uint64) ([]*TxOutWithProof, error) {
+	ring, _, err := rb.buildRing(realIndex)
+	return ring, err
+}
+
+func (rb *RingBuilder) buildRing(realIndex uint64) ([]*TxOutWithProof, *TxOut, error) {
+	numTxOuts, err := rb.Source.NumTxOuts()
+	if err != nil {
+		return nil, nil, err
+	}
+	if realIndex >= numTxOuts {
+		return nil, nil, fmt.Errorf("Invalid real index %d, tx outs %d", realIndex, numTxOuts)
+	}
+	if numTxOuts < RING_SIZE {
+		return nil, nil, ErrInsufficientTxOuts
+	}
+
+	distribution := rb.Distribution
+	if distribution == nil {
+		distribution = UniformDistribution{}
+	}
+	maxAttempts := rb.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DEFAULT_RING_MAX_ATTEMPTS
+	}
+
+	indices := []uint64{realIndex}
+	seen := map[uint64]bool{realIndex: true}
+	for attempts := 0; len(indices) < RING_SIZE && attempts < maxAttempts; attempts++ {
+		index, err := distribution.Sample(numTxOuts)
+		if err != nil {
+			return nil, nil, err
+		}
+		if index >= numTxOuts || seen[index] {
+			continue
+		}
+		seen[index] = true
+		indices = append(indices, index)
+	}
+	if len(indices) < RING_SIZE {
+		return nil, nil, fmt.Errorf("%w %d", ErrRingTooShort, len(indices))
+	}
+
+	txOuts, err := rb.Source.GetTxOuts(indices)
+	if err != nil {
+		return nil, nil, err
+	}
+	proofs, err := rb.Source.GetMembershipProofs(indices)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(txOuts) != len(indices) || len(proofs) != len(indices) {
+		return nil, nil, fmt.Errorf("Invalid tx outs %d and proofs %d for %d indices", len(txOuts), len(proofs), len(indices))
+	}
+
+	ring := make([]*TxOutWithProof, len(indices))
+	publicKeys := make(map[CompressedRistretto]bool)
+	for i := range indices {
+		if txOuts[i] == nil || proofs[i] == nil {
+			return nil, nil, fmt.Errorf("Invalid tx out %d", indices[i])
+		}
+		if publicKeys[txOuts[i].PublicKey] {
+			return nil, nil, fmt.Errorf("Duplicate ring element %s", txOuts[i].PublicKey)
+		}
+		publicKeys[txOuts[i].PublicKey] = true
+		ring[i] = &TxOutWithProof{TxOut: txOuts[i], Proof: proofs[i]}
+	}
+	real := txOuts[0]
+	sort.Slice(ring, func(i, j int) bool {
+		return ring[i].TxOut.PublicKey.Less(ring[j].TxOut.PublicKey)
+	})
+	return ring, real, nil
+}
+
+// NewInputCredential builds the ring of utxo, found at realIndex in the
+// ledger, and the input credential spending it.
+func (rb *RingBuilder) NewInputCredential(utxo *UTXO, realIndex uint64, viewPrivate string) (*InputCredential, error) {
+	ring, real, err := rb.buildRing(realIndex)
+	if err != nil {
+		return nil, err
+	}
+	proofSet := make(map[string]*TxOutMembershipProof)
+	for _, t := range ring {
+		proofSet[t.TxOut.PublicKey.String()] = t.Proof
+	}
+	input, err := NewInputCredential(utxo, proofSet, ring, viewPrivate)
+	if err != nil {
+		return nil, err
+	}
+	if input.Ring[input.RealIndex].PublicKey != real.PublicKey {
+		return nil, fmt.Errorf("Invalid utxo, tx out %d is %s", realIndex, real.PublicKey)
+	}
+	return input, nil
+}
+
+// MemoryTxOutSource is a TxOutSource kept in memory, tx outs are indexed
+// in the order they are added.
+type MemoryTxOutSource struct {
+	sync.RWMutex
+	txOuts []*TxOut
+	proofs []*TxOutMembershipProof
+}
+
+func NewMemoryTxOutSource() *MemoryTxOutSource {
+	return &MemoryTxOutSource{}
+}
+
+// Add appends txOut and returns its global index, when proof is nil a
+// proof holding only the index is used.
+func (s *MemoryTxOutSource) Add(txOut *TxOut, proof *TxOutMembershipProof) uint64 {
+	s.Lock()
+	defer s.Unlock()
+
+	index := uint64(len(s.txOuts))
+	if proof == nil {
+		i := strconv.FormatUint(index, 10)
+		proof = &TxOutMembershipProof{Index: i, HighestIndex: i}
+	}
+	s.txOuts = append(s.txOuts, txOut)
+	s.proofs = append(s.proofs, proof)
+	return index
+}
+
+func (s *MemoryTxOutSource) NumTxOuts() (uint64, error) {
+	s.RLock()
+	defer s.RUnlock()
+	return uint64(len(s.txOuts)), nil
+}
+
+func (s *MemoryTxOutSource) GetTxOuts(indices []uint64) ([]*TxOut, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	txOuts := make([]*TxOut, len(indices))
+	for i, index := range indices {
+		if index >= uint64(len(s.txOuts)) {
+			return nil, fmt.Errorf("Tx out %d not found", index)
+		}
+		txOuts[i] = s.txOuts[index]
+	}
+	return txOuts, nil
+}
+
+func (s *MemoryTxOutSource) GetMembershipProofs(indices []uint64) ([]*TxOutMembershipProof, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	proofs := make([]*TxOutMembershipProof, len(indices))
+	for i, index := range indices {
+		if index >= uint64(len(s.proofs)) {
+			return nil, fmt.Errorf("Tx out %d not found", index)
+		}
+		proofs[i] = s.proofs[index]
+	}
+	return proofs, nil
+}
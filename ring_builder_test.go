@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fixedDistribution uint64
+
+func (d fixedDistribution) Sample(numTxOuts uint64) (uint64, error) {
+	return uint64(d), nil
+}
+
+func newTestTxOutSource(n int) *MemoryTxOutSource {
+	source := NewMemoryTxOutSource()
+	for i := 0; i < n; i++ {
+		_, address := newTestAccount()
+		output, _, err := CreateOutput(MILLIMOB_TO_PICOMOB, address, 0)
+		if err != nil {
+			panic(err)
+		}
+		source.Add(output.Output, nil)
+	}
+	return source
+}
+
+func TestRingBuilder(t *testing.T) {
+	assert := assert.New(t)
+
+	source := newTestTxOutSource(30)
+	acc, address := newTestAccount()
+	real, _, err := CreateOutput(3*MILLIMOB_TO_PICOMOB, address, 0)
+	assert.Nil(err)
+	realIndex := source.Add(real.Output, nil)
+	for i := 0; i < 10; i++ {
+		_, decoy := newTestAccount()
+		output, _, err := CreateOutput(MILLIMOB_TO_PICOMOB, decoy, 0)
+		assert.Nil(err)
+		source.Add(output.Output, nil)
+	}
+
+	for _, distribution := range []DecoyDistribution{nil, &AgeDistribution{MeanAge: 10}} {
+		rb := NewRingBuilder(source, distribution)
+		ring, err := rb.BuildRing(realIndex)
+		assert.Nil(err)
+		assert.Len(ring, RING_SIZE)
+		var found bool
+		publicKeys := make(map[CompressedRistretto]bool)
+		for i, t := range ring {
+			found = found || t.TxOut == real.Output
+			publicKeys[t.TxOut.PublicKey] = true
+			assert.NotNil(t.Proof)
+			if i > 0 {
+				assert.True(ring[i-1].TxOut.PublicKey.Less(t.TxOut.PublicKey))
+			}
+		}
+		assert.True(found)
+		assert.Len(publicKeys, RING_SIZE)
+	}
+
+	data, err := json.Marshal(real.Output)
+	assert.Nil(err)
+	private := hex.EncodeToString(acc.ViewPrivateKey.Bytes()) + hex.EncodeToString(acc.SpendPrivateKey.Bytes())
+	utxo := &UTXO{Amount: 3 * MILLIMOB_TO_PICOMOB, PrivateKey: private, ScriptPubKey: hex.EncodeToString(data)}
+	rb := NewRingBuilder(source, &AgeDistribution{MeanAge: 20})
+	input, err := rb.NewInputCredential(utxo, realIndex, private[:64])
+	assert.Nil(err)
+	assert.Len(input.Ring, RING_SIZE)
+	assert.Equal(real.Output, input.Ring[input.RealIndex])
+
+	tb := &TransactionBuilder{
+		InputCredentials: []*InputCredential{input},
+		Fee:              MINIMUM_FEE,
+		TombstoneBlock:   150,
+		ChangeAddress:    address,
+	}
+	tx, err := tb.Build()
+	assert.Nil(err)
+	assert.Nil(ValidateTx(tx, 100))
+
+	_, err = rb.NewInputCredential(utxo, realIndex-1, private[:64])
+	assert.NotNil(err)
+	_, err = rb.BuildRing(100)
+	assert.NotNil(err)
+
+	_, err = NewRingBuilder(newTestTxOutSource(RING_SIZE-1), nil).BuildRing(0)
+	assert.True(errors.Is(err, ErrInsufficientTxOuts))
+	_, err = NewRingBuilder(source, fixedDistribution(1)).BuildRing(0)
+	assert.True(errors.Is(err, ErrRingTooShort))
+
+	// a struct literal samples uniformly with the default attempts
+	ring, err := (&RingBuilder{Source: source}).BuildRing(realIndex)
+	assert.Nil(err)
+	assert.Len(ring, RING_SIZE)
+
+	duplicated := newTestTxOutSource(RING_SIZE - 1)
+	first, _ := duplicated.GetTxOuts([]uint64{0})
+	duplicated.Add(first[0], nil)
+	_, err = NewRingBuilder(duplicated, nil).BuildRing(0)
+	assert.NotNil(err)
+}
+
+func TestAgeDistribution(t *testing.T) {
+	assert := assert.New(t)
+
+	d := &AgeDistribution{MeanAge: 100}
+	var recent int
+	for i := 0; i < 1000; i++ {
+		index, err := d.Sample(10000)
+		assert.Nil(err)
+		assert.True(index < 10000)
+		if index >= 10000-300 {
+			recent++
+		}
+	}
+	assert.True(recent > 900)
+
+	d1 := &AgeDistribution{MeanAge: 100, Rand: &testRNG{seed: []byte("seed")}}
+	d2 := &AgeDistribution{MeanAge: 100, Rand: &testRNG{seed: []byte("seed")}}
+	for i := 0; i < 10; i++ {
+		index1, err := d1.Sample(10000)
+		assert.Nil(err)
+		index2, err := d2.Sample(10000)
+		assert.Nil(err)
+		assert.Equal(index1, index2)
+	}
+
+	index, err := d.Sample(1)
+	assert.Nil(err)
+	assert.Equal(uint64(0), index)
+	_, err = d.Sample(0)
+	assert.NotNil(err)
+	for _, mean := range []float64{0, -1, math.NaN(), math.Inf(1)} {
+		_, err = (&AgeDistribution{MeanAge: mean}).Sample(10)
+		assert.NotNil(err)
+	}
+	_, err = (&AgeDistribution{MeanAge: math.MaxFloat64}).Sample(10)
+	assert.NotNil(err)
+}
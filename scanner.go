@@ -0,0 +1,193 @@
+package api
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/bwesterb/go-ristretto"
+	"github.com/dchest/blake2b"
+	account "github.com/jadeydi/mobilecoin-account"
+)
+
+const (
+	DEFAULT_SUBADDRESS_INDEX = 0
+	CHANGE_SUBADDRESS_INDEX  = 1
+
+	// DEFAULT_SUBADDRESS_LOOKAHEAD is the number of subaddresses a Scanner
+	// watches past the highest one it has seen.
+	DEFAULT_SUBADDRESS_LOOKAHEAD = 20
+)
+
+// Scanner finds the tx outs sent to the subaddresses of an account, it
+// keeps a table of their spend public keys which grows to stay lookahead
+// subaddresses past the highest one seen. It is safe for concurrent use.
+type Scanner struct {
+	viewPrivate  *ristretto.Scalar
+	spendPrivate *ristretto.Scalar
+	spendPublic  *ristretto.Point
+	lookahead    uint64
+
+	mutex        sync.RWMutex
+	subaddresses map[CompressedRistretto]uint64
+}
+
+// OwnedTxOut is a tx out received by the account, KeyImage is nil for a
+// view only Scanner.
+type OwnedTxOut struct {
+	TxOut           *TxOut
+	SubaddressIndex uint64
+	Value           uint64
+	Blinding        *ristretto.Scalar
+	KeyImage        *ristretto.Point
+}
+
+// NewScanner scans for acc, which must hold the spend private key to
+// compute the key images.
+func NewScanner(acc *account.Account, lookahead uint64) *Scanner {
+	s := newScanner(acc.ViewPrivateKey, account.PublicKey(acc.SpendPrivateKey), lookahead)
+	s.spendPrivate = acc.SpendPrivateKey
+	return s
+}
+
+// NewViewOnlyScanner scans with the view private key and the account spend
+// public key, it can't compute the key images.
+func NewViewOnlyScanner(viewPrivate *ristretto.Scalar, spendPublic *ristretto.Point, lookahead uint64) *Scanner {
+	return newScanner(viewPrivate, spendPublic, lookahead)
+}
+
+func newScanner(viewPrivate *ristretto.Scalar, spendPublic *ristretto.Point, lookahead uint64) *Scanner {
+	if lookahead < CHANGE_SUBADDRESS_INDEX+1 {
+		lookahead = CHANGE_SUBADDRESS_INDEX + 1
+	}
+	s := &Scanner{
+		viewPrivate:  viewPrivate,
+		spendPublic:  spendPublic,
+		lookahead:    lookahead,
+		subaddresses: make(map[CompressedRistretto]uint64),
+	}
+	s.extend(lookahead)
+	return s
+}
+
+// NumSubaddresses is the size of the table, the subaddresses [0, n) are
+// watched.
+func (s *Scanner) NumSubaddresses() uint64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return uint64(len(s.subaddresses))
+}
+
+// Scan returns nil when txOut doesn't belong to the account.
+// https://github.com/mobilecoinfoundation/mobilecoin/blob/master/libmobilecoin/src/transaction.rs
+// mc_tx_out_matches_any_subaddress
+func (s *Scanner) Scan(txOut *TxOut) (*OwnedTxOut, error) {
+	if txOut == nil || txOut.Amount == nil {
+		return nil, fmt.Errorf("Invalid tx out")
+	}
+	public, err := txOut.PublicKeyPoint()
+	if err != nil {
+		return nil, err
+	}
+	target, err := txOut.TargetKeyPoint()
+	if err != nil {
+		return nil, err
+	}
+
+	// D' = P - Hs(a * R) * G
+	hs := hashToScalar(public, s.viewPrivate)
+	var g, spend ristretto.Point
+	spend.Sub(target, g.ScalarMultBase(hs))
+	s.mutex.RLock()
+	index, ok := s.subaddresses[CompressedRistrettoFromPoint(&spend)]
+	s.mutex.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+	s.extend(index + 1 + s.lookahead)
+
+	value, blinding := GetValueWithBlinding(txOut, s.viewPrivate)
+	commitment := CompressedRistrettoFromPoint(NewCommitment(value, blinding))
+	if commitment != txOut.Amount.Commitment {
+		return nil, fmt.Errorf("Invalid commitment %s of tx out %s", txOut.Amount.Commitment, txOut.PublicKey)
+	}
+
+	owned := &OwnedTxOut{
+		TxOut:           txOut,
+		SubaddressIndex: index,
+		Value:           value,
+		Blinding:        blinding,
+	}
+	if s.spendPrivate != nil {
+		var onetimePrivate ristretto.Scalar
+		onetimePrivate.Add(hs, subaddressSpendPrivateKey(s.viewPrivate, s.spendPrivate, index))
+		owned.KeyImage = KeyImageFromPrivate(&onetimePrivate)
+	}
+	return owned, nil
+}
+
+// ScanTxOuts returns the owned tx outs of txOuts, in their order.
+func (s *Scanner) ScanTxOuts(txOuts []*TxOut) ([]*OwnedTxOut, error) {
+	var owned []*OwnedTxOut
+	for _, txOut := range txOuts {
+		o, err := s.Scan(txOut)
+		if err != nil {
+			return nil, err
+		}
+		if o != nil {
+			owned = append(owned, o)
+		}
+	}
+	return owned, nil
+}
+
+// UnspentTxOut is o in the shape the coin selection takes.
+func (o *OwnedTxOut) UnspentTxOut() *UnspentTxOut {
+	utxo := &UnspentTxOut{
+		TxOut:           o.TxOut,
+		SubaddressIndex: o.SubaddressIndex,
+		Value:           strconv.FormatUint(o.Value, 10),
+	}
+	if o.KeyImage != nil {
+		utxo.KeyImage = hex.EncodeToString(o.KeyImage.Bytes())
+	}
+	return utxo
+}
+
+func (s *Scanner) extend(n uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := uint64(len(s.subaddresses)); i < n; i++ {
+		key := subaddressSpendPublicKey(s.viewPrivate, s.spendPublic, i)
+		s.subaddresses[CompressedRistrettoFromPoint(key)] = i
+	}
+}
+
+// subaddressHash is Hs(a || n), the spend private key of the subaddress n
+// is d + Hs(a || n) and its public key D + Hs(a || n) * G.
+func subaddressHash(viewPrivate *ristretto.Scalar, index uint64) *ristretto.Scalar {
+	var buf [32]byte
+	binary.LittleEndian.PutUint64(buf[:], index)
+	var n ristretto.Scalar
+	hash := blake2b.New512()
+	hash.Write([]byte(account.SUBADDRESS_DOMAIN_TAG))
+	hash.Write(viewPrivate.Bytes())
+	hash.Write(n.SetBytes(&buf).Bytes())
+
+	var key [64]byte
+	copy(key[:], hash.Sum(nil))
+	var hs ristretto.Scalar
+	return hs.SetReduced(&key)
+}
+
+func subaddressSpendPublicKey(viewPrivate *ristretto.Scalar, spendPublic *ristretto.Point, index uint64) *ristretto.Point {
+	var g, key ristretto.Point
+	return key.Add(spendPublic, g.ScalarMultBase(subaddressHash(viewPrivate, index)))
+}
+
+func subaddressSpendPrivateKey(viewPrivate, spendPrivate *ristretto.Scalar, index uint64) *ristretto.Scalar {
+	var key ristretto.Scalar
+	return key.Add(subaddressHash(viewPrivate, index), spendPrivate)
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"encoding/hex"
+	"testing"
+
+	account "github.com/jadeydi/mobilecoin-account"
+	"github.com/stretchr/testify/assert"
+)
+
+func testSubaddress(acc *account.Account, index uint64) *account.PublicAddress {
+	spendPrivate := acc.SubaddressSpendPrivateKey(index)
+	viewPrivate := acc.SubaddressViewPrivateKey(spendPrivate)
+	return &account.PublicAddress{
+		ViewPublicKey:  hex.EncodeToString(account.PublicKey(viewPrivate).Bytes()),
+		SpendPublicKey: hex.EncodeToString(account.PublicKey(spendPrivate).Bytes()),
+	}
+}
+
+func TestScanner(t *testing.T) {
+	assert := assert.New(t)
+
+	acc, address := newTestAccount()
+	scanner := NewScanner(acc, 5)
+	assert.Equal(uint64(5), scanner.NumSubaddresses())
+
+	output, _, err := CreateOutput(3*MILLIMOB_TO_PICOMOB, address, 0)
+	assert.Nil(err)
+	owned, err := scanner.Scan(output.Output)
+	assert.Nil(err)
+	assert.Equal(uint64(0), owned.SubaddressIndex)
+	assert.Equal(uint64(3*MILLIMOB_TO_PICOMOB), owned.Value)
+	_, blinding := GetValueWithBlinding(output.Output, acc.ViewPrivateKey)
+	assert.Equal(blinding.Bytes(), owned.Blinding.Bytes())
+	public, _ := output.Output.PublicKeyPoint()
+	onetimePrivate := hashToScalar(public, acc.ViewPrivateKey)
+	onetimePrivate.Add(onetimePrivate, acc.SubaddressSpendPrivateKey(0))
+	assert.Equal(KeyImageFromPrivate(onetimePrivate).Bytes(), owned.KeyImage.Bytes())
+	assert.Equal(uint64(6), scanner.NumSubaddresses())
+
+	// change, then subaddresses past the table once the lookahead moves
+	for _, index := range []uint64{CHANGE_SUBADDRESS_INDEX, 4, 8, 12} {
+		output, _, err := CreateOutput(MILLIMOB_TO_PICOMOB+index, testSubaddress(acc, index), 0)
+		assert.Nil(err)
+		owned, err := scanner.Scan(output.Output)
+		assert.Nil(err)
+		assert.Equal(index, owned.SubaddressIndex)
+		assert.Equal(MILLIMOB_TO_PICOMOB+index, owned.Value)
+		assert.Equal(index+1+5, scanner.NumSubaddresses())
+	}
+	output, _, err = CreateOutput(MILLIMOB_TO_PICOMOB, testSubaddress(acc, 30), 0)
+	assert.Nil(err)
+	owned, err = scanner.Scan(output.Output)
+	assert.Nil(err)
+	assert.Nil(owned)
+
+	_, other := newTestAccount()
+	foreign, _, err := CreateOutput(MILLIMOB_TO_PICOMOB, other, 0)
+	assert.Nil(err)
+	owned, err = scanner.Scan(foreign.Output)
+	assert.Nil(err)
+	assert.Nil(owned)
+
+	change, _, err := CreateOutput(2*MILLIMOB_TO_PICOMOB, testSubaddress(acc, CHANGE_SUBADDRESS_INDEX), 0)
+	assert.Nil(err)
+	viewOnly := NewViewOnlyScanner(acc.ViewPrivateKey, account.PublicKey(acc.SpendPrivateKey), DEFAULT_SUBADDRESS_LOOKAHEAD)
+	all, err := viewOnly.ScanTxOuts([]*TxOut{foreign.Output, change.Output, output.Output})
+	assert.Nil(err)
+	assert.Len(all, 1)
+	assert.Equal(uint64(CHANGE_SUBADDRESS_INDEX), all[0].SubaddressIndex)
+	assert.Nil(all[0].KeyImage)
+
+	owned, err = scanner.Scan(change.Output)
+	assert.Nil(err)
+	utxo := owned.UnspentTxOut()
+	assert.Equal(change.Output, utxo.TxOut)
+	assert.Equal("2000000000", utxo.Value)
+	assert.Equal(uint64(CHANGE_SUBADDRESS_INDEX), utxo.SubaddressIndex)
+	assert.Equal(hex.EncodeToString(owned.KeyImage.Bytes()), utxo.KeyImage)
+
+	tampered := *change.Output
+	tampered.Amount = &Amount{Commitment: change.Output.Amount.Commitment, MaskedValue: change.Output.Amount.MaskedValue + 1}
+	_, err = scanner.Scan(&tampered)
+	assert.NotNil(err)
+	tampered.TargetKey = CompressedRistretto{0xff}
+	_, err = scanner.Scan(&tampered)
+	assert.NotNil(err)
+}
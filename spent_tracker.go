@@ -0,0 +1,429 @@
+package api
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jadeydi/mobilecoin-account/block"
+)
+
+type TxOutStatus int
+
+const (
+	TxOutUnspent TxOutStatus = iota
+	// TxOutPending is an output spent by a submitted transaction which is
+	// not in the ledger yet, it is unspent again once the tombstone block
+	// of the transaction is passed.
+	TxOutPending
+	TxOutSpent
+)
+
+var txOutStatusNames = map[TxOutStatus]string{
+	TxOutUnspent: "Unspent",
+	TxOutPending: "Pending",
+	TxOutSpent:   "Spent",
+}
+
+func (s TxOutStatus) String() string {
+	if name, ok := txOutStatusNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("TxOutStatus(%d)", int(s))
+}
+
+// KeyImageChecker is a ledger service telling which key images are spent,
+// e.g. the fog ledger CheckKeyImages.
+type KeyImageChecker interface {
+	// CheckKeyImages returns the block index of the spent key images among
+	// keyImages and the number of blocks of the ledger checked.
+	CheckKeyImages(ctx context.Context, keyImages []string) (map[string]uint64, uint64, error)
+}
+
+// TrackedTxOut is an owned output with its status, SpentBlock is only set
+// for a spent output.
+type TrackedTxOut struct {
+	*UnspentTxOut
+	ReceivedBlock uint64      `json:"received_block"`
+	SpentBlock    uint64      `json:"spent_block"`
+	Status        TxOutStatus `json:"status"`
+}
+
+// Balance is the value of the tracked outputs by status.
+type Balance struct {
+	Unspent uint64
+	Pending uint64
+	Spent   uint64
+}
+
+// SpentTracker follows the owned outputs of an account by their key image,
+// an output is spent once its key image shows up in the ledger. It is safe
+// for concurrent use.
+//
+// Only the key images of tracked outputs are kept, an output added after
+// the block spending it, e.g. by a scan lagging behind the ledger, is
+// unspent until Sync checks its key image.
+type SpentTracker struct {
+	privateKey string
+
+	mutex      sync.RWMutex
+	outputs    map[string]*TrackedTxOut
+	order      []string
+	keyImages  map[string]uint64
+	blockCount uint64
+
+	// the changes since the last call to Changes
+	changedTxOuts    map[string]bool
+	changedKeyImages map[string]bool
+	changedCount     bool
+}
+
+// NewSpentTracker computes the key images with privateKey, the hex of the
+// view and spend private keys. It can be empty when the outputs come with
+// their key image, e.g. from a Scanner.
+func NewSpentTracker(privateKey string) *SpentTracker {
+	return &SpentTracker{
+		privateKey:       privateKey,
+		outputs:          make(map[string]*TrackedTxOut),
+		keyImages:        make(map[string]uint64),
+		changedTxOuts:    make(map[string]bool),
+		changedKeyImages: make(map[string]bool),
+	}
+}
+
+// KeyImageOfTxOut is the hex of the key image of txOut, received on the
+// subaddress subaddressIndex of the account privateKey.
+func KeyImageOfTxOut(txOut *TxOut, privateKey string, subaddressIndex uint64) (string, error) {
+	onetimePrivateKey, err := RecoverOnetimePrivateKey(txOut, privateKey, subaddressIndex)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(KeyImageFromPrivate(onetimePrivateKey).Bytes()), nil
+}
+
+// Add tracks utxo, received in the block receivedBlock. The key image is
+// computed when utxo has none, and checked when the tracker has the
+// private key. Adding an output twice does nothing.
+func (t *SpentTracker) Add(utxo *UnspentTxOut, receivedBlock uint64) error {
+	if _, err := unspentValue(utxo); err != nil {
+		return err
+	}
+	keyImage := utxo.KeyImage
+	if t.privateKey != "" {
+		computed, err := KeyImageOfTxOut(utxo.TxOut, t.privateKey, utxo.SubaddressIndex)
+		if err != nil {
+			return err
+		}
+		if keyImage != "" && keyImage != computed {
+			return fmt.Errorf("Invalid key image %s of tx out %s, expected %s", keyImage, utxo.TxOut.PublicKey, computed)
+		}
+		keyImage = computed
+	}
+	if keyImage == "" {
+		return fmt.Errorf("Invalid tx out %s without key image", utxo.TxOut.PublicKey)
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if _, ok := t.outputs[keyImage]; ok {
+		return nil
+	}
+	u := *utxo
+	u.KeyImage = keyImage
+	t.outputs[keyImage] = &TrackedTxOut{UnspentTxOut: &u, ReceivedBlock: receivedBlock}
+	t.order = append(t.order, keyImage)
+	t.changedTxOuts[keyImage] = true
+	return nil
+}
+
+// AddOwned tracks an output found by a Scanner.
+func (t *SpentTracker) AddOwned(owned *OwnedTxOut, receivedBlock uint64) error {
+	return t.Add(owned.UnspentTxOut(), receivedBlock)
+}
+
+// IngestKeyImages records the key images spent in the block blockIndex,
+// blocks are ingested in order and one before BlockCount is rejected. It
+// returns the outputs it spent, and the pending outputs whose tombstone
+// block is passed are unspent again.
+func (t *SpentTracker) IngestKeyImages(blockIndex uint64, keyImages []string) ([]*TrackedTxOut, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if blockIndex < t.blockCount {
+		return nil, fmt.Errorf("Invalid block %d, block count %d", blockIndex, t.blockCount)
+	}
+	var spent []*TrackedTxOut
+	for _, keyImage := range keyImages {
+		t.recordKeyImage(keyImage, blockIndex)
+		if tracked := t.spend(keyImage, blockIndex); tracked != nil {
+			spent = append(spent, tracked)
+		}
+	}
+	t.advance(blockIndex + 1)
+	return spent, nil
+}
+
+// IngestBlockContents records the key images of the block blockIndex.
+func (t *SpentTracker) IngestBlockContents(blockIndex uint64, contents *block.BlockContents) ([]*TrackedTxOut, error) {
+	keyImages := make([]string, 0, len(contents.GetKeyImages()))
+	for _, keyImage := range contents.GetKeyImages() {
+		keyImages = append(keyImages, hex.EncodeToString(keyImage.GetData()))
+	}
+	return t.IngestKeyImages(blockIndex, keyImages)
+}
+
+// MarkSpent spends the output of keyImage at the block blockIndex, e.g.
+// from the response of a ledger service. The block of an output already
+// spent is replaced.
+func (t *SpentTracker) MarkSpent(keyImage string, blockIndex uint64) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	tracked, ok := t.outputs[keyImage]
+	if !ok {
+		return fmt.Errorf("Tx out of key image %s not found", keyImage)
+	}
+	t.keyImages[keyImage] = blockIndex
+	t.changedKeyImages[keyImage] = true
+	if tracked.Status == TxOutSpent && tracked.SpentBlock != blockIndex {
+		tracked.SpentBlock = blockIndex
+		t.changedTxOuts[keyImage] = true
+	}
+	t.spend(keyImage, blockIndex)
+	return nil
+}
+
+// MarkPending marks the inputs of tx pending until its tombstone block, all
+// of them must be tracked and unspent.
+func (t *SpentTracker) MarkPending(tx *Tx) error {
+	if tx == nil || tx.Prefix == nil || tx.Signature == nil {
+		return errors.New("Invalid tx")
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	tombstone := uint64(tx.Prefix.TombstoneBlock)
+	if tombstone <= t.blockCount {
+		return fmt.Errorf("Invalid tombstone block %d, block count %d", tombstone, t.blockCount)
+	}
+	var inputs []*TrackedTxOut
+	for _, signature := range tx.Signature.RingSignatures {
+		tracked, ok := t.outputs[signature.KeyImage]
+		if !ok {
+			return fmt.Errorf("Tx out of key image %s not found", signature.KeyImage)
+		}
+		if tracked.Status != TxOutUnspent {
+			return fmt.Errorf("Invalid tx out of key image %s, %s", signature.KeyImage, tracked.Status)
+		}
+		inputs = append(inputs, tracked)
+	}
+	for _, tracked := range inputs {
+		tracked.Status = TxOutPending
+		tracked.AttemptedSpendHeight = t.blockCount
+		tracked.AttemptedSpendTombstone = tombstone
+		t.changedTxOuts[tracked.KeyImage] = true
+	}
+	return nil
+}
+
+// Sync asks checker about the key images of the unspent and pending
+// outputs.
+func (t *SpentTracker) Sync(ctx context.Context, checker KeyImageChecker) error {
+	t.mutex.RLock()
+	var keyImages []string
+	for _, keyImage := range t.order {
+		if t.outputs[keyImage].Status != TxOutSpent {
+			keyImages = append(keyImages, keyImage)
+		}
+	}
+	t.mutex.RUnlock()
+	if len(keyImages) == 0 {
+		return nil
+	}
+
+	spent, blockCount, err := checker.CheckKeyImages(ctx, keyImages)
+	if err != nil {
+		return err
+	}
+	for keyImage, blockIndex := range spent {
+		if blockIndex >= blockCount {
+			return fmt.Errorf("Invalid key image %s spent at block %d, block count %d", keyImage, blockIndex, blockCount)
+		}
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for keyImage, blockIndex := range spent {
+		t.recordKeyImage(keyImage, blockIndex)
+		t.spend(keyImage, blockIndex)
+	}
+	t.advance(blockCount)
+	return nil
+}
+
+// Restore replaces the state of the tracker with the one of a WalletStore.
+func (t *SpentTracker) Restore(state *WalletState) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.outputs = make(map[string]*TrackedTxOut, len(state.TxOuts))
+	t.order = make([]string, 0, len(state.TxOuts))
+	for _, tracked := range state.TxOuts {
+		if _, ok := t.outputs[tracked.KeyImage]; !ok {
+			t.order = append(t.order, tracked.KeyImage)
+		}
+		t.outputs[tracked.KeyImage] = tracked.copy()
+	}
+	t.keyImages = make(map[string]uint64, len(state.KeyImages))
+	for keyImage, blockIndex := range state.KeyImages {
+		if _, ok := t.outputs[keyImage]; ok {
+			t.keyImages[keyImage] = blockIndex
+		}
+	}
+	t.blockCount = state.Checkpoints[SPENT_TRACKER_CHECKPOINT]
+	t.changedTxOuts = make(map[string]bool)
+	t.changedKeyImages = make(map[string]bool)
+	t.changedCount = false
+}
+
+// Changes returns the outputs, key images and block count changed since
+// the last call, for WalletStore.Apply. When Apply fails the tracker has
+// to be restored from the store.
+func (t *SpentTracker) Changes() *WalletUpdate {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	update := &WalletUpdate{}
+	for _, keyImage := range t.order {
+		if t.changedTxOuts[keyImage] {
+			update.TxOuts = append(update.TxOuts, t.outputs[keyImage].copy())
+		}
+	}
+	if len(t.changedKeyImages) > 0 {
+		update.KeyImages = make(map[string]uint64, len(t.changedKeyImages))
+		for keyImage := range t.changedKeyImages {
+			update.KeyImages[keyImage] = t.keyImages[keyImage]
+		}
+	}
+	if t.changedCount {
+		update.Checkpoints = map[string]uint64{SPENT_TRACKER_CHECKPOINT: t.blockCount}
+	}
+	t.changedTxOuts = make(map[string]bool)
+	t.changedKeyImages = make(map[string]bool)
+	t.changedCount = false
+	return update
+}
+
+// Get returns nil when no output of keyImage is tracked.
+func (t *SpentTracker) Get(keyImage string) *TrackedTxOut {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	tracked, ok := t.outputs[keyImage]
+	if !ok {
+		return nil
+	}
+	return tracked.copy()
+}
+
+// Unspent is the outputs coin selection can spend, pending ones excluded.
+func (t *SpentTracker) Unspent() []*UnspentTxOut {
+	return t.view(TxOutUnspent)
+}
+
+func (t *SpentTracker) Pending() []*UnspentTxOut {
+	return t.view(TxOutPending)
+}
+
+func (t *SpentTracker) Spent() []*UnspentTxOut {
+	return t.view(TxOutSpent)
+}
+
+func (t *SpentTracker) Balance() *Balance {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	balance := &Balance{}
+	for _, keyImage := range t.order {
+		tracked := t.outputs[keyImage]
+		// the value is checked by Add
+		value, _ := unspentValue(tracked.UnspentTxOut)
+		switch tracked.Status {
+		case TxOutUnspent:
+			balance.Unspent += value
+		case TxOutPending:
+			balance.Pending += value
+		case TxOutSpent:
+			balance.Spent += value
+		}
+	}
+	return balance
+}
+
+// BlockCount is the number of ledger blocks ingested.
+func (t *SpentTracker) BlockCount() uint64 {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.blockCount
+}
+
+// view returns copies of the outputs of status in the order they were
+// added, the tracker keeps updating its own.
+func (t *SpentTracker) view(status TxOutStatus) []*UnspentTxOut {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	var utxos []*UnspentTxOut
+	for _, keyImage := range t.order {
+		tracked := t.outputs[keyImage]
+		if tracked.Status == status {
+			utxos = append(utxos, tracked.copy().UnspentTxOut)
+		}
+	}
+	return utxos
+}
+
+func (t *SpentTracker) spend(keyImage string, blockIndex uint64) *TrackedTxOut {
+	tracked, ok := t.outputs[keyImage]
+	if !ok || tracked.Status == TxOutSpent {
+		return nil
+	}
+	tracked.Status = TxOutSpent
+	tracked.SpentBlock = blockIndex
+	t.changedTxOuts[keyImage] = true
+	return tracked.copy()
+}
+
+func (t *SpentTracker) advance(blockCount uint64) {
+	if blockCount > t.blockCount {
+		t.blockCount = blockCount
+		t.changedCount = true
+	}
+	for _, keyImage := range t.order {
+		tracked := t.outputs[keyImage]
+		if tracked.Status == TxOutPending && tracked.AttemptedSpendTombstone <= t.blockCount {
+			tracked.Status = TxOutUnspent
+			t.changedTxOuts[keyImage] = true
+		}
+	}
+}
+
+// recordKeyImage keeps the first block the key image of a tracked output is
+// seen at, the other key images of the ledger are dropped.
+func (t *SpentTracker) recordKeyImage(keyImage string, blockIndex uint64) {
+	if _, ok := t.outputs[keyImage]; !ok {
+		return
+	}
+	if _, ok := t.keyImages[keyImage]; !ok {
+		t.keyImages[keyImage] = blockIndex
+		t.changedKeyImages[keyImage] = true
+	}
+}
+
+func (o *TrackedTxOut) copy() *TrackedTxOut {
+	u := *o.UnspentTxOut
+	tracked := *o
+	tracked.UnspentTxOut = &u
+	return &tracked
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"encoding/hex"
+	"strconv"
+	"testing"
+
+	"github.com/jadeydi/mobilecoin-account/block"
+	"github.com/stretchr/testify/assert"
+)
+
+type testKeyImageChecker struct {
+	spent      map[string]uint64
+	blockCount uint64
+	checked    []string
+}
+
+func (c *testKeyImageChecker) CheckKeyImages(ctx context.Context, keyImages []string) (map[string]uint64, uint64, error) {
+	c.checked = keyImages
+	spent := make(map[string]uint64)
+	for _, keyImage := range keyImages {
+		if blockIndex, ok := c.spent[keyImage]; ok {
+			spent[keyImage] = blockIndex
+		}
+	}
+	return spent, c.blockCount, nil
+}
+
+func ingestKeyImages(tracker *SpentTracker, blockIndex uint64, keyImages []string) []*TrackedTxOut {
+	spent, err := tracker.IngestKeyImages(blockIndex, keyImages)
+	if err != nil {
+		panic(err)
+	}
+	return spent
+}
+
+func TestSpentTracker(t *testing.T) {
+	assert := assert.New(t)
+
+	acc, address := newTestAccount()
+	private := hex.EncodeToString(acc.ViewPrivateKey.Bytes()) + hex.EncodeToString(acc.SpendPrivateKey.Bytes())
+	tracker := NewSpentTracker(private)
+	var inputs []*InputCredential
+	var keyImages []string
+	for i := 3; i > 0; i-- {
+		input := newTestInputCredential(acc, address, uint64(i)*MILLIMOB_TO_PICOMOB)
+		inputs = append(inputs, input)
+		utxo := &UnspentTxOut{
+			TxOut: input.Ring[input.RealIndex],
+			Value: strconv.FormatUint(uint64(i)*MILLIMOB_TO_PICOMOB, 10),
+		}
+		assert.Nil(tracker.Add(utxo, 10))
+		assert.Nil(tracker.Add(utxo, 10))
+		assert.Equal("", utxo.KeyImage)
+		keyImage, err := KeyImageOfTxOut(utxo.TxOut, private, 0)
+		assert.Nil(err)
+		keyImages = append(keyImages, keyImage)
+		assert.Equal(TxOutUnspent, tracker.Get(keyImage).Status)
+	}
+	assert.Len(tracker.Unspent(), 3)
+	assert.Equal(&Balance{Unspent: 6 * MILLIMOB_TO_PICOMOB}, tracker.Balance())
+
+	// the key images of a scanner match
+	scanner := NewScanner(acc, DEFAULT_SUBADDRESS_LOOKAHEAD)
+	owned, err := scanner.Scan(inputs[0].Ring[inputs[0].RealIndex])
+	assert.Nil(err)
+	scanned := NewSpentTracker("")
+	assert.Nil(scanned.AddOwned(owned, 10))
+	assert.NotNil(scanned.Get(keyImages[0]))
+	assert.NotNil(scanned.Add(&UnspentTxOut{TxOut: owned.TxOut, Value: "1"}, 10))
+	wrong := owned.UnspentTxOut()
+	wrong.KeyImage = keyImages[1]
+	assert.NotNil(tracker.Add(wrong, 10))
+	wrong.SubaddressIndex = CHANGE_SUBADDRESS_INDEX
+	wrong.KeyImage = ""
+	assert.NotNil(tracker.Add(wrong, 10))
+
+	build := func(input *InputCredential, tombstone uint64) *Tx {
+		tx, err := NewTransactionBuilder(&TransactionBuilderOptions{Fee: MINIMUM_FEE, TombstoneBlock: tombstone, ChangeAddress: address}).
+			AddInput(input).
+			Build()
+		assert.Nil(err)
+		return tx
+	}
+	tx := build(inputs[0], 150)
+	assert.Equal(keyImages[0], tx.Signature.RingSignatures[0].KeyImage)
+	assert.Nil(tracker.MarkPending(tx))
+	assert.NotNil(tracker.MarkPending(tx))
+	pending := tracker.Pending()
+	assert.Len(pending, 1)
+	assert.Equal(uint64(150), pending[0].AttemptedSpendTombstone)
+	assert.Len(tracker.Unspent(), 2)
+	assert.Equal(&Balance{Unspent: 3 * MILLIMOB_TO_PICOMOB, Pending: 3 * MILLIMOB_TO_PICOMOB}, tracker.Balance())
+
+	// the views are copies
+	pending[0].Value = "0"
+	assert.Equal("3000000000", tracker.Pending()[0].Value)
+
+	// a pending output is unspent again after the tombstone block
+	assert.Nil(tracker.MarkPending(build(inputs[1], 120)))
+	assert.Len(ingestKeyImages(tracker, 118, []string{"00"}), 0)
+	assert.Len(tracker.Pending(), 2)
+	assert.Len(ingestKeyImages(tracker, 119, nil), 0)
+	assert.Len(tracker.Pending(), 1)
+	assert.Equal(uint64(120), tracker.BlockCount())
+	assert.NotNil(tracker.MarkPending(build(inputs[1], 120)))
+
+	data, _ := hex.DecodeString(keyImages[0])
+	spent, err := tracker.IngestBlockContents(120, &block.BlockContents{KeyImages: []*block.KeyImage{{Data: data}}})
+	assert.Nil(err)
+	assert.Len(spent, 1)
+	assert.Equal(keyImages[0], spent[0].KeyImage)
+	assert.Equal(uint64(120), spent[0].SpentBlock)
+	assert.Equal(TxOutSpent, tracker.Get(keyImages[0]).Status)
+	assert.Len(tracker.Pending(), 0)
+	assert.Len(tracker.Spent(), 1)
+	assert.Len(ingestKeyImages(tracker, 121, []string{keyImages[0]}), 0)
+	assert.Equal(uint64(120), tracker.Get(keyImages[0]).SpentBlock)
+	// the blocks come in order
+	_, err = tracker.IngestKeyImages(120, []string{keyImages[1]})
+	assert.NotNil(err)
+	assert.Equal(TxOutUnspent, tracker.Get(keyImages[1]).Status)
+	assert.Equal(uint64(122), tracker.BlockCount())
+
+	selection, err := SelectInputs(tracker.Unspent(), MILLIMOB_TO_PICOMOB, MINIMUM_FEE, LargestFirst)
+	assert.Nil(err)
+	assert.Equal(keyImages[1], selection.Inputs[0].KeyImage)
+
+	// a key image seen before its output is not kept, Sync spends the output
+	late := newTestInputCredential(acc, address, MILLIMOB_TO_PICOMOB)
+	keyImage, err := KeyImageOfTxOut(late.Ring[late.RealIndex], private, 0)
+	assert.Nil(err)
+	ingestKeyImages(tracker, 122, []string{keyImage})
+	assert.Nil(tracker.Add(&UnspentTxOut{TxOut: late.Ring[late.RealIndex], Value: "1000000000"}, 121))
+	assert.Equal(TxOutUnspent, tracker.Get(keyImage).Status)
+
+	checker := &testKeyImageChecker{spent: map[string]uint64{keyImages[2]: 125, keyImage: 122}, blockCount: 130}
+	assert.Nil(tracker.Sync(context.Background(), checker))
+	assert.Equal(append(keyImages[1:], keyImage), checker.checked)
+	assert.Equal(uint64(125), tracker.Get(keyImages[2]).SpentBlock)
+	assert.Equal(TxOutSpent, tracker.Get(keyImage).Status)
+	assert.Equal(uint64(122), tracker.Get(keyImage).SpentBlock)
+	assert.Equal(uint64(130), tracker.BlockCount())
+	checker.spent[keyImages[1]] = 130
+	assert.NotNil(tracker.Sync(context.Background(), checker))
+	assert.Equal(TxOutUnspent, tracker.Get(keyImages[1]).Status)
+
+	assert.Nil(tracker.MarkSpent(keyImages[1], 129))
+	assert.NotNil(tracker.MarkSpent("00", 129))
+	// marking a spent output again moves its block
+	tracker.Changes()
+	assert.Nil(tracker.MarkSpent(keyImages[1], 128))
+	assert.Equal(uint64(128), tracker.Get(keyImages[1]).SpentBlock)
+	update := tracker.Changes()
+	assert.Equal(map[string]uint64{keyImages[1]: 128}, update.KeyImages)
+	assert.Len(update.TxOuts, 1)
+	assert.Equal(uint64(128), update.TxOuts[0].SpentBlock)
+	assert.Nil(tracker.Get("00"))
+	assert.Len(tracker.Unspent(), 0)
+	assert.Equal(&Balance{Spent: 7 * MILLIMOB_TO_PICOMOB}, tracker.Balance())
+	assert.Equal("Pending", TxOutPending.String())
+}
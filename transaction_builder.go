@@ -1,9 +1,14 @@
 package api
 
 import (
+	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"sort"
+	"strconv"
 
 	"github.com/bwesterb/go-ristretto"
 	account "github.com/jadeydi/mobilecoin-account"
@@ -31,6 +36,9 @@ type UTXO struct {
 	Amount          uint64
 	PrivateKey      string
 	ScriptPubKey    string
+	// SubaddressIndex is the subaddress which received the output, e.g.
+	// CHANGE_SUBADDRESS_INDEX for change.
+	SubaddressIndex uint64
 }
 
 type InputCredential struct {
@@ -40,8 +48,12 @@ type InputCredential struct {
 	OnetimePrivateKey   *ristretto.Scalar
 	RealOutputPublicKey *ristretto.Point
 	ViewPrivateKey      *ristretto.Scalar
+	// SubaddressIndex is the subaddress which received the real output.
+	SubaddressIndex uint64
 }
 
+// NewInputCredential spends utxo in the ring tops, which must hold the tx out
+// of utxo, its membership proof is taken from proofSet when it is there.
 func NewInputCredential(utxo *UTXO, proofSet map[string]*TxOutMembershipProof, tops []*TxOutWithProof, viewPrivate string) (*InputCredential, error) {
 	data, err := hex.DecodeString(utxo.ScriptPubKey)
 	if err != nil {
@@ -52,58 +64,60 @@ func NewInputCredential(utxo *UTXO, proofSet map[string]*TxOutMembershipProof, t
 	if err != nil {
 		return nil, err
 	}
-	proof := proofSet[txOut.PublicKey]
+	proof := proofSet[txOut.PublicKey.String()]
 
-	onetimePrivateKey, err := RecoverOnetimePrivateKey(txOut.PublicKey, utxo.PrivateKey)
+	onetimePrivateKey, err := RecoverOnetimePrivateKey(&txOut, utxo.PrivateKey, utxo.SubaddressIndex)
 	if err != nil {
 		return nil, err
 	}
 
-	var realIndex int
+	// sort a copy, the ring of the caller is left as is
+	ring := make([]*TxOutWithProof, len(tops))
 	for i := range tops {
-		if tops[i].TxOut.PublicKey == txOut.PublicKey {
-			realIndex = i
-			break
+		if tops[i] == nil || tops[i].TxOut == nil {
+			return nil, fmt.Errorf("Invalid ring element %d", i)
 		}
+		ring[i] = tops[i]
 	}
-	if realIndex == 0 {
-		t := &TxOutWithProof{
-			TxOut: &txOut,
-			Proof: proof,
-		}
-		if len(tops) == 0 {
-			tops = append(tops, t)
-		} else {
-			tops[0] = t
-		}
-	}
-
-	sort.Slice(tops, func(i, j int) bool {
-		return tops[i].TxOut.PublicKey < tops[j].TxOut.PublicKey
+	sort.Slice(ring, func(i, j int) bool {
+		return ring[i].TxOut.PublicKey.Less(ring[j].TxOut.PublicKey)
 	})
 
-	for i := range tops {
-		if tops[i].TxOut.PublicKey == txOut.PublicKey {
+	var realIndex int
+	var found bool
+	for i := range ring {
+		if ring[i].TxOut.PublicKey == txOut.PublicKey {
 			realIndex = i
+			found = true
 			break
 		}
 	}
+	if !found {
+		return nil, fmt.Errorf("Invalid ring without the real output %s", txOut.PublicKey)
+	}
 
-	realOutputPublicKey := hexToPoint(txOut.PublicKey)
-	ring := make([]*TxOut, len(tops))
-	proofs := make([]*TxOutMembershipProof, len(tops))
-	for i := range tops {
-		ring[i] = tops[i].TxOut
-		proofs[i] = tops[i].Proof
+	realOutputPublicKey, err := txOut.PublicKeyPoint()
+	if err != nil {
+		return nil, err
+	}
+	txOuts := make([]*TxOut, len(ring))
+	proofs := make([]*TxOutMembershipProof, len(ring))
+	for i := range ring {
+		txOuts[i] = ring[i].TxOut
+		proofs[i] = ring[i].Proof
+	}
+	if proof != nil {
+		proofs[realIndex] = proof
 	}
 
 	return &InputCredential{
-		Ring:                ring,
+		Ring:                txOuts,
 		MembershipProofs:    proofs,
 		RealIndex:           realIndex,
 		OnetimePrivateKey:   onetimePrivateKey,
 		ViewPrivateKey:      account.ViewPrivateKeyFromHex(viewPrivate),
 		RealOutputPublicKey: realOutputPublicKey,
+		SubaddressIndex:     utxo.SubaddressIndex,
 	}, nil
 }
 
@@ -113,6 +127,7 @@ type OutputAndSharedSecret struct {
 	Index        int
 	Value        uint64
 	Receiver     *account.PublicAddress
+	IsChange     bool
 }
 
 func (o *OutputAndSharedSecret) GetValueWithBlinding() (uint64, *ristretto.Scalar) {
@@ -129,28 +144,221 @@ type TransactionBuilder struct {
 	OutputsAndSharedSecrets []*OutputAndSharedSecret `json:"outputs_and_shared_secrets"`
 	TombstoneBlock          uint64                   `json:"tombstone_block"`
 	Fee                     uint64                   `json:"fee"`
+
+	// ChangeAddress receives sum(inputs) - sum(outputs) - fee, the change
+	// output is added by Build and marked with IsChange.
+	ChangeAddress *account.PublicAddress `json:"change_address"`
+	// OmitZeroChange allows Build to skip the change output when there is
+	// no change left, otherwise a zero value change output is still added.
+	OmitZeroChange bool `json:"omit_zero_change"`
+
+	// Rand is the source of all the randomness of the outputs and the
+	// signature, crypto/rand when nil.
+	Rand io.Reader `json:"-"`
+
+	// the earliest fog pubkey expiry of the outputs, 0 when unknown
+	fogPubkeyExpiry uint64
+	change          *OutputAndSharedSecret
+	err             error
+}
+
+type TransactionBuilderOptions struct {
+	Fee            uint64
+	TombstoneBlock uint64
+	ChangeAddress  *account.PublicAddress
+	OmitZeroChange bool
+	Rand           io.Reader
+}
+
+// NewTransactionBuilder returns a builder to fill with AddInput and
+// AddOutput, every step is validated and the first error is kept and
+// returned by Err and Build.
+func NewTransactionBuilder(opts *TransactionBuilderOptions) *TransactionBuilder {
+	tb := &TransactionBuilder{}
+	if opts == nil {
+		return tb
+	}
+	tb.ChangeAddress = opts.ChangeAddress
+	tb.OmitZeroChange = opts.OmitZeroChange
+	tb.Rand = opts.Rand
+	if opts.Fee > 0 {
+		tb.SetFee(opts.Fee)
+	}
+	if opts.TombstoneBlock > 0 {
+		tb.SetTombstone(opts.TombstoneBlock)
+	}
+	return tb
+}
+
+func (tb *TransactionBuilder) Err() error {
+	return tb.err
+}
+
+func (tb *TransactionBuilder) rng() io.Reader {
+	if tb.Rand == nil {
+		return rand.Reader
+	}
+	return tb.Rand
+}
+
+func (tb *TransactionBuilder) fail(err error) *TransactionBuilder {
+	if tb.err == nil {
+		tb.err = err
+	}
+	return tb
+}
+
+func (tb *TransactionBuilder) AddInput(input *InputCredential) *TransactionBuilder {
+	if tb.err != nil {
+		return tb
+	}
+	if input == nil || input.OnetimePrivateKey == nil || input.ViewPrivateKey == nil {
+		return tb.fail(errors.New("Invalid input credential"))
+	}
+	if len(input.Ring) != RING_SIZE || len(input.MembershipProofs) != len(input.Ring) {
+		return tb.fail(fmt.Errorf("Invalid input ring size %d, proofs %d", len(input.Ring), len(input.MembershipProofs)))
+	}
+	if input.RealIndex < 0 || input.RealIndex >= len(input.Ring) {
+		return tb.fail(fmt.Errorf("Invalid input real index %d", input.RealIndex))
+	}
+	if len(tb.InputCredentials) >= MAX_INPUTS {
+		return tb.fail(fmt.Errorf("Too many inputs, maximum %d", MAX_INPUTS))
+	}
+	real := input.Ring[input.RealIndex].PublicKey
+	for _, in := range tb.InputCredentials {
+		if in.Ring[in.RealIndex].PublicKey == real {
+			return tb.fail(fmt.Errorf("Duplicate input %s", real))
+		}
+	}
+	tb.InputCredentials = append(tb.InputCredentials, input)
+	return tb
+}
+
+func (tb *TransactionBuilder) AddOutput(value uint64, recipient *account.PublicAddress) *TransactionBuilder {
+	if tb.err != nil {
+		return tb
+	}
+	if recipient == nil {
+		return tb.fail(errors.New("Invalid recipient"))
+	}
+	hint, pubkeyExpiry, err := CreateFogHintWithRNG(recipient, tb.rng())
+	if err != nil {
+		return tb.fail(err)
+	}
+	return tb.AddOutputWithFogHint(value, recipient, hint, pubkeyExpiry)
+}
+
+// AddOutputWithFogHint adds an output with a fog hint encrypted by the
+// caller, pubkeyExpiry is the expiry of the fog pubkey which encrypted it
+// and bounds the tombstone block.
+func (tb *TransactionBuilder) AddOutputWithFogHint(value uint64, recipient *account.PublicAddress, hint []byte, pubkeyExpiry uint64) *TransactionBuilder {
+	if tb.err != nil {
+		return tb
+	}
+	if recipient == nil {
+		return tb.fail(errors.New("Invalid recipient"))
+	}
+	if len(hint) != EncryptedFogHintSize {
+		return tb.fail(fmt.Errorf("Invalid fog hint length %d", len(hint)))
+	}
+	if len(tb.OutputsAndSharedSecrets) >= MAX_OUTPUTS {
+		return tb.fail(fmt.Errorf("Too many outputs, maximum %d", MAX_OUTPUTS))
+	}
+	if tb.TombstoneBlock > pubkeyExpiry {
+		return tb.fail(fmt.Errorf("Tombstone block %d exceeds fog pubkey expiry %d", tb.TombstoneBlock, pubkeyExpiry))
+	}
+	if tb.fogPubkeyExpiry == 0 || pubkeyExpiry < tb.fogPubkeyExpiry {
+		tb.fogPubkeyExpiry = pubkeyExpiry
+	}
+	output, err := createOutputWithFogHint(value, recipient, hint, len(tb.OutputsAndSharedSecrets), tb.rng())
+	if err != nil {
+		return tb.fail(err)
+	}
+	tb.OutputsAndSharedSecrets = append(tb.OutputsAndSharedSecrets, output)
+	return tb
+}
+
+func (tb *TransactionBuilder) SetFee(fee uint64) *TransactionBuilder {
+	if tb.err != nil {
+		return tb
+	}
+	if fee == 0 {
+		return tb.fail(errors.New("Invalid fee 0"))
+	}
+	tb.Fee = fee
+	return tb
+}
+
+func (tb *TransactionBuilder) SetTombstone(block uint64) *TransactionBuilder {
+	if tb.err != nil {
+		return tb
+	}
+	if block == 0 {
+		return tb.fail(errors.New("Invalid tombstone block 0"))
+	}
+	if tb.fogPubkeyExpiry > 0 && block > tb.fogPubkeyExpiry {
+		return tb.fail(fmt.Errorf("Tombstone block %d exceeds fog pubkey expiry %d", block, tb.fogPubkeyExpiry))
+	}
+	tb.TombstoneBlock = block
+	return tb
 }
 
+// Change returns the change output of the last Build, if any.
+func (tb *TransactionBuilder) Change() *OutputAndSharedSecret {
+	return tb.change
+}
+
+// Build signs the transaction, the inputs and outputs are sorted as the
+// consensus requires on copies, the builder and caller slices keep their
+// order.
 func (tb *TransactionBuilder) Build() (*Tx, error) {
-	sort.Slice(tb.InputCredentials, func(i, j int) bool {
-		return tb.InputCredentials[i].Ring[0].PublicKey < tb.InputCredentials[j].Ring[0].PublicKey
+	tx, _, _, err := tb.build()
+	return tx, err
+}
+
+func (tb *TransactionBuilder) build() (*Tx, []*InputCredential, []*OutputAndSharedSecret, error) {
+	if tb.err != nil {
+		return nil, nil, nil, tb.err
+	}
+	if len(tb.InputCredentials) == 0 || len(tb.InputCredentials) > MAX_INPUTS {
+		return nil, nil, nil, fmt.Errorf("Invalid inputs count %d", len(tb.InputCredentials))
+	}
+	if tb.fogPubkeyExpiry > 0 && tb.TombstoneBlock > tb.fogPubkeyExpiry {
+		return nil, nil, nil, fmt.Errorf("Tombstone block %d exceeds fog pubkey expiry %d", tb.TombstoneBlock, tb.fogPubkeyExpiry)
+	}
+
+	inputs := append([]*InputCredential{}, tb.InputCredentials...)
+	outputs := append([]*OutputAndSharedSecret{}, tb.OutputsAndSharedSecrets...)
+	change, err := tb.changeOutput(inputs, outputs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if change != nil {
+		outputs = append(outputs, change)
+	}
+	if len(outputs) == 0 || len(outputs) > MAX_OUTPUTS {
+		return nil, nil, nil, fmt.Errorf("Invalid outputs count %d", len(outputs))
+	}
+
+	sort.Slice(inputs, func(i, j int) bool {
+		return inputs[i].Ring[0].PublicKey.Less(inputs[j].Ring[0].PublicKey)
 	})
 
-	inputList := make([]*TxIn, len(tb.InputCredentials))
-	for i := range tb.InputCredentials {
+	inputList := make([]*TxIn, len(inputs))
+	for i := range inputs {
 		inputList[i] = &TxIn{
-			Ring:   tb.InputCredentials[i].Ring,
-			Proofs: tb.InputCredentials[i].MembershipProofs,
+			Ring:   inputs[i].Ring,
+			Proofs: inputs[i].MembershipProofs,
 		}
 	}
 
-	sort.Slice(tb.OutputsAndSharedSecrets, func(i, j int) bool {
-		return tb.OutputsAndSharedSecrets[i].Output.PublicKey < tb.OutputsAndSharedSecrets[j].Output.PublicKey
+	sort.Slice(outputs, func(i, j int) bool {
+		return outputs[i].Output.PublicKey.Less(outputs[j].Output.PublicKey)
 	})
 
-	outputList := make([]*TxOut, len(tb.OutputsAndSharedSecrets))
-	for i := range tb.OutputsAndSharedSecrets {
-		outputList[i] = tb.OutputsAndSharedSecrets[i].Output
+	outputList := make([]*TxOut, len(outputs))
+	for i := range outputs {
+		outputList[i] = outputs[i].Output
 	}
 
 	txPrefix := &TxPrefix{
@@ -160,14 +368,107 @@ func (tb *TransactionBuilder) Build() (*Tx, error) {
 		TombstoneBlock: TombstoneValue(tb.TombstoneBlock),
 	}
 
-	message := HashOfTxPrefix(txPrefix)
-	signatures, err := SignRctBulletproofs(message, tb.InputCredentials, tb.Fee, tb.OutputsAndSharedSecrets)
+	message, err := HashOfTxPrefix(txPrefix)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
+	}
+	signatures, err := SignRctBulletproofsWithRNG(message, inputs, tb.Fee, outputs, tb.rng())
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
+	tb.change = change
 	return &Tx{
 		Prefix:    txPrefix,
 		Signature: signatures,
-	}, nil
+	}, inputs, outputs, nil
+}
+
+// changeOutput checks the value is conserved and creates the change output
+// when there is a change address.
+func (tb *TransactionBuilder) changeOutput(inputs []*InputCredential, outputs []*OutputAndSharedSecret) (*OutputAndSharedSecret, error) {
+	var inputValue, outputValue uint64
+	for _, input := range inputs {
+		value, _ := GetValueWithBlinding(input.Ring[input.RealIndex], input.ViewPrivateKey)
+		if inputValue+value < inputValue {
+			return nil, errors.New("Invalid input values overflow")
+		}
+		inputValue += value
+	}
+	for _, output := range outputs {
+		if outputValue+output.Value < outputValue {
+			return nil, errors.New("Invalid output values overflow")
+		}
+		outputValue += output.Value
+	}
+	if outputValue+tb.Fee < outputValue || inputValue < outputValue+tb.Fee {
+		return nil, fmt.Errorf("Insufficient input value %d, outputs %d, fee %d", inputValue, outputValue, tb.Fee)
+	}
+
+	change := inputValue - outputValue - tb.Fee
+	if tb.ChangeAddress == nil {
+		if change != 0 {
+			return nil, fmt.Errorf("Value not conserved, inputs %d, outputs %d, fee %d", inputValue, outputValue, tb.Fee)
+		}
+		return nil, nil
+	}
+	if change == 0 && tb.OmitZeroChange {
+		return nil, nil
+	}
+	output, _, err := CreateOutputWithRNG(change, tb.ChangeAddress, len(outputs), tb.rng())
+	if err != nil {
+		return nil, err
+	}
+	output.IsChange = true
+	return output, nil
+}
+
+// BuildTxProposal builds the transaction and the TxProposal describing it,
+// the outlays are the outputs of the builder in their order, the change
+// output is not one of them.
+func (tb *TransactionBuilder) BuildTxProposal() (*TxProposal, error) {
+	tx, inputs, outputs, err := tb.build()
+	if err != nil {
+		return nil, err
+	}
+
+	txOutIndex := make(map[*OutputAndSharedSecret]int, len(outputs))
+	for i, output := range outputs {
+		txOutIndex[output] = i
+	}
+
+	proposal := &TxProposal{
+		InputList:                 make([]*UnspentTxOut, len(inputs)),
+		OutlayList:                make([]*Outlay, 0, len(outputs)),
+		Tx:                        tx,
+		Fee:                       tb.Fee,
+		OutlayIndexToTxOutIndex:   make([][]int, 0, len(outputs)),
+		OutlayConfirmationNumbers: make([][]int, 0, len(outputs)),
+	}
+	for i, input := range inputs {
+		real := input.Ring[input.RealIndex]
+		value, _ := GetValueWithBlinding(real, input.ViewPrivateKey)
+		proposal.InputList[i] = &UnspentTxOut{
+			TxOut:           real,
+			SubaddressIndex: input.SubaddressIndex,
+			KeyImage:        hex.EncodeToString(KeyImageFromPrivate(input.OnetimePrivateKey).Bytes()),
+			Value:           strconv.FormatUint(value, 10),
+		}
+	}
+	for _, output := range tb.OutputsAndSharedSecrets {
+		outlayIndex := len(proposal.OutlayList)
+		proposal.OutlayList = append(proposal.OutlayList, &Outlay{
+			Value:    strconv.FormatUint(output.Value, 10),
+			Receiver: output.Receiver,
+		})
+		proposal.OutlayIndexToTxOutIndex = append(proposal.OutlayIndexToTxOutIndex, []int{outlayIndex, txOutIndex[output]})
+
+		confirmation := ConfirmationNumberFromSecret(output.SharedSecret)
+		number := make([]int, len(confirmation))
+		for i := range confirmation {
+			number[i] = int(confirmation[i])
+		}
+		proposal.OutlayConfirmationNumbers = append(proposal.OutlayConfirmationNumbers, number)
+	}
+	return proposal, nil
 }
@@ -0,0 +1,353 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	account "github.com/jadeydi/mobilecoin-account"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionBuilderChange(t *testing.T) {
+	assert := assert.New(t)
+
+	acc, address := newTestAccount()
+	_, recipient := newTestAccount()
+	payment, _, err := CreateOutput(2*MILLIMOB_TO_PICOMOB, recipient, 0)
+	assert.Nil(err)
+
+	tb := &TransactionBuilder{
+		InputCredentials:        []*InputCredential{newTestInputCredential(acc, address, 3*MILLIMOB_TO_PICOMOB)},
+		OutputsAndSharedSecrets: []*OutputAndSharedSecret{payment},
+		Fee:                     MINIMUM_FEE,
+		TombstoneBlock:          150,
+		ChangeAddress:           address,
+	}
+	tx, err := tb.Build()
+	assert.Nil(err)
+	assert.Nil(ValidateTx(tx, 100))
+	assert.Len(tx.Prefix.Outputs, 2)
+	assert.Len(tb.OutputsAndSharedSecrets, 1)
+
+	change := tb.Change()
+	assert.NotNil(change)
+	assert.True(change.IsChange)
+	assert.False(payment.IsChange)
+	assert.Equal(uint64(MILLIMOB_TO_PICOMOB-MINIMUM_FEE), change.Value)
+	value, _ := GetValueWithBlinding(change.Output, acc.ViewPrivateKey)
+	assert.Equal(uint64(MILLIMOB_TO_PICOMOB-MINIMUM_FEE), value)
+
+	// building again creates a new change output
+	tx, err = tb.Build()
+	assert.Nil(err)
+	assert.Len(tx.Prefix.Outputs, 2)
+	assert.NotEqual(change, tb.Change())
+
+	// zero change
+	payment, _, err = CreateOutput(3*MILLIMOB_TO_PICOMOB-MINIMUM_FEE, recipient, 0)
+	assert.Nil(err)
+	tb.OutputsAndSharedSecrets = []*OutputAndSharedSecret{payment}
+	tx, err = tb.Build()
+	assert.Nil(err)
+	assert.Nil(ValidateTx(tx, 100))
+	assert.Len(tx.Prefix.Outputs, 2)
+
+	tb.OmitZeroChange = true
+	tx, err = tb.Build()
+	assert.Nil(err)
+	assert.Nil(ValidateTx(tx, 100))
+	assert.Len(tx.Prefix.Outputs, 1)
+	assert.Nil(tb.Change())
+
+	tb.Fee = MINIMUM_FEE + 1
+	_, err = tb.Build()
+	assert.NotNil(err)
+}
+
+func TestNewTransactionBuilder(t *testing.T) {
+	assert := assert.New(t)
+
+	acc, address := newTestAccount()
+	_, recipient := newTestAccount()
+	inputs := []*InputCredential{
+		newTestInputCredential(acc, address, 3*MILLIMOB_TO_PICOMOB),
+		newTestInputCredential(acc, address, 2*MILLIMOB_TO_PICOMOB),
+	}
+	if inputs[0].Ring[0].PublicKey.Less(inputs[1].Ring[0].PublicKey) {
+		inputs[0], inputs[1] = inputs[1], inputs[0]
+	}
+	rings := [][]*TxOut{append([]*TxOut{}, inputs[0].Ring...), append([]*TxOut{}, inputs[1].Ring...)}
+
+	tb := NewTransactionBuilder(&TransactionBuilderOptions{ChangeAddress: address})
+	tx, err := tb.AddInput(inputs[0]).
+		AddInput(inputs[1]).
+		AddOutput(2*MILLIMOB_TO_PICOMOB, recipient).
+		AddOutput(MILLIMOB_TO_PICOMOB, recipient).
+		SetFee(MINIMUM_FEE).
+		SetTombstone(150).
+		Build()
+	assert.Nil(err)
+	assert.Nil(ValidateTx(tx, 100))
+	assert.Len(tx.Prefix.Outputs, 3)
+	assert.Equal(uint64(2*MILLIMOB_TO_PICOMOB-MINIMUM_FEE), tb.Change().Value)
+
+	// caller and builder data keep their order
+	assert.True(inputs[1].Ring[0].PublicKey.Less(inputs[0].Ring[0].PublicKey))
+	assert.Equal(inputs[0], tb.InputCredentials[0])
+	assert.Equal(rings[0], inputs[0].Ring)
+	assert.Equal(rings[1], inputs[1].Ring)
+	assert.Equal(uint64(2*MILLIMOB_TO_PICOMOB), tb.OutputsAndSharedSecrets[0].Value)
+	assert.Equal(uint64(MILLIMOB_TO_PICOMOB), tb.OutputsAndSharedSecrets[1].Value)
+
+	// value not conserved without a change address
+	_, err = NewTransactionBuilder(&TransactionBuilderOptions{Fee: MINIMUM_FEE, TombstoneBlock: 150}).
+		AddInput(inputs[0]).
+		AddOutput(MILLIMOB_TO_PICOMOB/2, recipient).
+		Build()
+	assert.NotNil(err)
+	_, err = NewTransactionBuilder(&TransactionBuilderOptions{Fee: MINIMUM_FEE, TombstoneBlock: 150}).
+		AddInput(inputs[0]).
+		AddOutput(5*MILLIMOB_TO_PICOMOB, recipient).
+		Build()
+	assert.NotNil(err)
+	value, _ := GetValueWithBlinding(inputs[1].Ring[inputs[1].RealIndex], acc.ViewPrivateKey)
+	tx, err = NewTransactionBuilder(&TransactionBuilderOptions{Fee: MINIMUM_FEE, TombstoneBlock: 150}).
+		AddInput(inputs[1]).
+		AddOutput(value-MINIMUM_FEE, recipient).
+		Build()
+	assert.Nil(err)
+	assert.Nil(ValidateTx(tx, 100))
+
+	tb = NewTransactionBuilder(nil).AddInput(inputs[0]).AddInput(inputs[0])
+	assert.NotNil(tb.Err())
+	assert.Len(tb.InputCredentials, 1)
+	_, err = tb.SetFee(MINIMUM_FEE).Build()
+	assert.Equal(tb.Err(), err)
+
+	assert.NotNil(NewTransactionBuilder(nil).AddInput(nil).Err())
+	short := *inputs[0]
+	short.Ring = short.Ring[1:]
+	assert.NotNil(NewTransactionBuilder(nil).AddInput(&short).Err())
+	assert.NotNil(NewTransactionBuilder(nil).SetFee(0).Err())
+	assert.NotNil(NewTransactionBuilder(nil).SetTombstone(0).Err())
+	assert.NotNil(NewTransactionBuilder(nil).AddOutput(1, nil).Err())
+	assert.NotNil(NewTransactionBuilder(nil).AddOutputWithFogHint(1, recipient, []byte{1}, 100).Err())
+
+	// tombstone is bounded by the fog pubkey expiry
+	hint, _, err := CreateFogHint(recipient)
+	assert.Nil(err)
+	tb = NewTransactionBuilder(nil).AddOutputWithFogHint(1, recipient, hint, 120)
+	assert.Nil(tb.Err())
+	assert.NotNil(tb.SetTombstone(121).Err())
+	tb = NewTransactionBuilder(nil).SetTombstone(150).AddOutputWithFogHint(1, recipient, hint, 120)
+	assert.NotNil(tb.Err())
+
+	// malformed recipient keys
+	for _, key := range []string{"zz", "00", strings.Repeat("ff", 32)} {
+		malformed := *recipient
+		malformed.ViewPublicKey = key
+		assert.NotNil(NewTransactionBuilder(nil).AddOutput(1, &malformed).Err())
+		malformed = *recipient
+		malformed.SpendPublicKey = key
+		assert.NotNil(NewTransactionBuilder(nil).AddOutputWithFogHint(1, &malformed, hint, 120).Err())
+	}
+
+	tb = NewTransactionBuilder(nil)
+	for i := 0; i < MAX_OUTPUTS; i++ {
+		tb.AddOutput(1, recipient)
+	}
+	assert.Nil(tb.Err())
+	assert.NotNil(tb.AddOutput(1, recipient).Err())
+}
+
+func TestBuildTxProposal(t *testing.T) {
+	assert := assert.New(t)
+
+	acc, address := newTestAccount()
+	recipients := make([]*account.Account, 3)
+	tb := NewTransactionBuilder(&TransactionBuilderOptions{Fee: MINIMUM_FEE, TombstoneBlock: 150, ChangeAddress: address})
+	tb.AddInput(newTestInputCredential(acc, address, 7*MILLIMOB_TO_PICOMOB))
+	for i := range recipients {
+		var recipient *account.PublicAddress
+		recipients[i], recipient = newTestAccount()
+		tb.AddOutput(uint64(i+1)*MILLIMOB_TO_PICOMOB, recipient)
+	}
+	proposal, err := tb.BuildTxProposal()
+	assert.Nil(err)
+	assert.Nil(ValidateTx(proposal.Tx, 100))
+	assert.Equal(uint64(MINIMUM_FEE), proposal.Fee)
+	assert.Len(proposal.Tx.Prefix.Outputs, 4)
+	assert.Len(proposal.InputList, 1)
+	assert.Equal("7000000000", proposal.InputList[0].Value)
+	assert.Equal(proposal.Tx.Signature.RingSignatures[0].KeyImage, proposal.InputList[0].KeyImage)
+	assert.Len(proposal.OutlayList, 3)
+	assert.Len(proposal.OutlayIndexToTxOutIndex, 3)
+	assert.Len(proposal.OutlayConfirmationNumbers, 3)
+
+	for i, outlay := range proposal.OutlayList {
+		assert.Equal(strconv.FormatUint(uint64(i+1)*MILLIMOB_TO_PICOMOB, 10), outlay.Value)
+		assert.Equal(i, proposal.OutlayIndexToTxOutIndex[i][0])
+		txOut := proposal.Tx.Prefix.Outputs[proposal.OutlayIndexToTxOutIndex[i][1]]
+		value, _ := GetValueWithBlinding(txOut, recipients[i].ViewPrivateKey)
+		assert.Equal(uint64(i+1)*MILLIMOB_TO_PICOMOB, value)
+
+		// the recipient computes the same confirmation number
+		public, err := txOut.PublicKeyPoint()
+		assert.Nil(err)
+		secret := createSharedSecret(public, recipients[i].ViewPrivateKey)
+		confirmation := ConfirmationNumberFromSecret(secret)
+		assert.Len(proposal.OutlayConfirmationNumbers[i], 32)
+		for j := range confirmation {
+			assert.Equal(int(confirmation[j]), proposal.OutlayConfirmationNumbers[i][j])
+		}
+	}
+
+	data, err := json.Marshal(proposal)
+	assert.Nil(err)
+	var fields map[string]json.RawMessage
+	assert.Nil(json.Unmarshal(data, &fields))
+	assert.Equal("400000000", string(fields["fee"]))
+	assert.Equal(fmt.Sprintf("[[0,%d],[1,%d],[2,%d]]", proposal.OutlayIndexToTxOutIndex[0][1], proposal.OutlayIndexToTxOutIndex[1][1], proposal.OutlayIndexToTxOutIndex[2][1]), string(fields["outlay_index_to_tx_out_index"]))
+	var decoded TxProposal
+	assert.Nil(json.Unmarshal(data, &decoded))
+	redata, err := json.Marshal(&decoded)
+	assert.Nil(err)
+	assert.Equal(string(data), string(redata))
+
+	_, err = NewTransactionBuilder(nil).BuildTxProposal()
+	assert.NotNil(err)
+}
+
+// testRNG is a deterministic io.Reader, a stream of SHA-256 of the seed and
+// a counter.
+type testRNG struct {
+	seed    []byte
+	counter uint64
+	buf     []byte
+}
+
+func (r *testRNG) Read(p []byte) (int, error) {
+	for len(r.buf) < len(p) {
+		var counter [8]byte
+		binary.LittleEndian.PutUint64(counter[:], r.counter)
+		r.counter++
+		block := sha256.Sum256(append(append([]byte{}, r.seed...), counter[:]...))
+		r.buf = append(r.buf, block[:]...)
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func TestTransactionBuilderRand(t *testing.T) {
+	assert := assert.New(t)
+
+	acc, address := newTestAccount()
+	_, recipient := newTestAccount()
+	input := newTestInputCredential(acc, address, 3*MILLIMOB_TO_PICOMOB)
+
+	build := func(seed string) []byte {
+		tb := NewTransactionBuilder(&TransactionBuilderOptions{
+			Fee:            MINIMUM_FEE,
+			TombstoneBlock: 150,
+			ChangeAddress:  address,
+			Rand:           &testRNG{seed: []byte(seed)},
+		})
+		proposal, err := tb.AddInput(input).AddOutput(MILLIMOB_TO_PICOMOB, recipient).BuildTxProposal()
+		assert.Nil(err)
+		assert.Nil(ValidateTx(proposal.Tx, 100))
+		data, err := json.Marshal(proposal)
+		assert.Nil(err)
+		return data
+	}
+	assert.Equal(build("seed"), build("seed"))
+	assert.NotEqual(build("seed"), build("other"))
+
+	output1, _, err := CreateOutputWithRNG(MILLIMOB_TO_PICOMOB, recipient, 0, &testRNG{seed: []byte("seed")})
+	assert.Nil(err)
+	output2, _, err := CreateOutputWithRNG(MILLIMOB_TO_PICOMOB, recipient, 0, &testRNG{seed: []byte("seed")})
+	assert.Nil(err)
+	assert.Equal(output1.Output, output2.Output)
+
+	_, _, err = CreateOutputWithRNG(MILLIMOB_TO_PICOMOB, recipient, 0, bytes.NewReader(nil))
+	assert.NotNil(err)
+	_, err = NewTransactionBuilder(&TransactionBuilderOptions{Rand: bytes.NewReader(make([]byte, 200))}).
+		AddInput(input).
+		AddOutput(3*MILLIMOB_TO_PICOMOB-MINIMUM_FEE, recipient).
+		SetFee(MINIMUM_FEE).
+		SetTombstone(150).
+		Build()
+	assert.NotNil(err)
+}
+
+func TestSpendSubaddress(t *testing.T) {
+	assert := assert.New(t)
+
+	acc, address := newTestAccount()
+	private := hex.EncodeToString(acc.ViewPrivateKey.Bytes()) + hex.EncodeToString(acc.SpendPrivateKey.Bytes())
+	change, _, err := CreateOutput(3*MILLIMOB_TO_PICOMOB, testSubaddress(acc, CHANGE_SUBADDRESS_INDEX), 0)
+	assert.Nil(err)
+	tops := []*TxOutWithProof{{TxOut: change.Output, Proof: newTestMembershipProof()}}
+	for len(tops) < RING_SIZE {
+		_, decoy := newTestAccount()
+		output, _, err := CreateOutput(MILLIMOB_TO_PICOMOB, decoy, 0)
+		assert.Nil(err)
+		tops = append(tops, &TxOutWithProof{TxOut: output.Output, Proof: newTestMembershipProof()})
+	}
+	data, err := json.Marshal(change.Output)
+	assert.Nil(err)
+	proofs := map[string]*TxOutMembershipProof{change.Output.PublicKey.String(): newTestMembershipProof()}
+	utxo := &UTXO{
+		Amount:          3 * MILLIMOB_TO_PICOMOB,
+		PrivateKey:      private,
+		ScriptPubKey:    hex.EncodeToString(data),
+		SubaddressIndex: CHANGE_SUBADDRESS_INDEX,
+	}
+	input, err := NewInputCredential(utxo, proofs, tops, private[:64])
+	assert.Nil(err)
+	tb := &TransactionBuilder{
+		InputCredentials: []*InputCredential{input},
+		Fee:              MINIMUM_FEE,
+		TombstoneBlock:   150,
+		ChangeAddress:    address,
+	}
+	tx, err := tb.Build()
+	assert.Nil(err)
+	assert.Nil(ValidateTx(tx, 100))
+
+	// the proposal keeps the subaddress of the input
+	assert.Equal(uint64(CHANGE_SUBADDRESS_INDEX), input.SubaddressIndex)
+	proposal, err := tb.BuildTxProposal()
+	assert.Nil(err)
+	data, err = json.Marshal(proposal)
+	assert.Nil(err)
+	var decoded TxProposal
+	assert.Nil(json.Unmarshal(data, &decoded))
+	assert.Equal(uint64(CHANGE_SUBADDRESS_INDEX), decoded.InputList[0].SubaddressIndex)
+	_, err = RecoverOnetimePrivateKey(decoded.InputList[0].TxOut, private, decoded.InputList[0].SubaddressIndex)
+	assert.Nil(err)
+
+	utxo.SubaddressIndex = DEFAULT_SUBADDRESS_INDEX
+	_, err = NewInputCredential(utxo, proofs, tops, private[:64])
+	assert.NotNil(err)
+
+	// the ring must hold the real output, and is not sorted in place
+	assert.Equal(change.Output, tops[0].TxOut)
+	utxo.SubaddressIndex = CHANGE_SUBADDRESS_INDEX
+	_, err = NewInputCredential(utxo, proofs, tops[1:], private[:64])
+	assert.NotNil(err)
+	_, err = RecoverOnetimePrivateKey(change.Output, private, 2)
+	assert.NotNil(err)
+	_, err = RecoverOnetimePrivateKey(change.Output, private[:64], CHANGE_SUBADDRESS_INDEX)
+	assert.NotNil(err)
+	key, err := RecoverOnetimePrivateKey(change.Output, private, CHANGE_SUBADDRESS_INDEX)
+	assert.Nil(err)
+	assert.Equal(input.OnetimePrivateKey.Bytes(), key.Bytes())
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/gtank/merlin"
+)
+
+type TxHash [32]byte
+
+func (h TxHash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// Hash is the digest of the whole Tx, prefix and signature, with the
+// scheme of HashOfTxPrefix. An unsigned Tx has no hash, it is not the Tx
+// the network will see.
+// https://github.com/mobilecoinfoundation/mobilecoin/blob/master/transaction/core/src/tx.rs
+func (tx *Tx) Hash() (TxHash, error) {
+	var hash TxHash
+	if tx.Prefix == nil {
+		return hash, errors.New("Invalid Tx without prefix")
+	}
+	if tx.Signature == nil {
+		return hash, errors.New("Invalid Tx without signature")
+	}
+	t := merlin.NewTranscript("digestible")
+	appendBytes([]byte("mobilecoin-tx"), []byte(AGGREGATE), t)
+	appendBytes([]byte("name"), []byte("Tx"), t)
+
+	if err := appendTxPrefix("prefix", tx.Prefix, t); err != nil {
+		return hash, err
+	}
+	if err := appendSignature(tx.Signature, t); err != nil {
+		return hash, err
+	}
+
+	appendBytes([]byte("mobilecoin-tx"), []byte(AGGREGATE_END), t)
+	appendBytes([]byte("name"), []byte("Tx"), t)
+
+	copy(hash[:], t.ExtractBytes([]byte("digest32"), 32))
+	return hash, nil
+}
+
+// Hash is the digest of the TxOut, the e_memo is omitted when empty as an
+// Option is. It follows the Rust digestible scheme but is not checked yet
+// against vectors of mc-transaction-core or of ledger blocks.
+func (o *TxOut) Hash() ([32]byte, error) {
+	var hash [32]byte
+	t := merlin.NewTranscript("digestible")
+	if err := appendTxOut("mobilecoin-txout", o, t); err != nil {
+		return hash, err
+	}
+	copy(hash[:], t.ExtractBytes([]byte("digest32"), 32))
+	return hash, nil
+}
+
+// KeyImages are the key images of the spent inputs, in the order of the
+// ring signatures.
+func (tx *Tx) KeyImages() []string {
+	if tx.Signature == nil {
+		return nil
+	}
+	keyImages := make([]string, len(tx.Signature.RingSignatures))
+	for i, signature := range tx.Signature.RingSignatures {
+		keyImages[i] = signature.KeyImage
+	}
+	return keyImages
+}
+
+// OutputPublicKeys are the hex public keys of the outputs, which identify
+// them in the ledger.
+func (tx *Tx) OutputPublicKeys() []string {
+	if tx.Prefix == nil {
+		return nil
+	}
+	keys := make([]string, len(tx.Prefix.Outputs))
+	for i, output := range tx.Prefix.Outputs {
+		keys[i] = output.PublicKey.String()
+	}
+	return keys
+}
+
+func appendHexPrimitive(context, typ, h string, t *merlin.Transcript) error {
+	buf, err := hex.DecodeString(h)
+	if err != nil {
+		return fmt.Errorf("Invalid %s %s", typ, h)
+	}
+	appendBytes([]byte(context), []byte(PRIMITIVE), t)
+	appendBytes([]byte(typ), buf, t)
+	return nil
+}
+
+func appendSeqHeader(context string, n int, t *merlin.Transcript) {
+	appendBytes([]byte(context), []byte(SEQUENCE), t)
+	bytes := make([]byte, 8)
+	binary.LittleEndian.PutUint64(bytes, uint64(n))
+	appendBytes([]byte("len"), bytes, t)
+}
+
+func appendRingMLSAG(sig *RingMLSAG, t *merlin.Transcript) error {
+	if sig == nil {
+		return errors.New("Invalid RingMLSAG nil")
+	}
+	appendBytes([]byte(""), []byte(AGGREGATE), t)
+	appendBytes([]byte("name"), []byte("RingMLSAG"), t)
+
+	if err := appendHexPrimitive("c_zero", "scalar", sig.CZero, t); err != nil {
+		return err
+	}
+	appendSeqHeader("responses", len(sig.Responses), t)
+	for _, response := range sig.Responses {
+		if err := appendHexPrimitive("", "scalar", response, t); err != nil {
+			return err
+		}
+	}
+	if err := appendHexPrimitive("key_image", "ristretto", sig.KeyImage, t); err != nil {
+		return err
+	}
+
+	appendBytes([]byte(""), []byte(AGGREGATE_END), t)
+	appendBytes([]byte("name"), []byte("RingMLSAG"), t)
+	return nil
+}
+
+func appendSignature(signature *SignatureRctBulletproofs, t *merlin.Transcript) error {
+	appendBytes([]byte("signature"), []byte(AGGREGATE), t)
+	appendBytes([]byte("name"), []byte("SignatureRctBulletproofs"), t)
+
+	appendSeqHeader("ring_signatures", len(signature.RingSignatures), t)
+	for _, sig := range signature.RingSignatures {
+		if err := appendRingMLSAG(sig, t); err != nil {
+			return err
+		}
+	}
+	appendSeqHeader("pseudo_output_commitments", len(signature.PseudoOutputCommitments), t)
+	for _, commitment := range signature.PseudoOutputCommitments {
+		if err := appendHexPrimitive("", "ristretto", commitment, t); err != nil {
+			return err
+		}
+	}
+	if err := appendHexPrimitive("range_proof_bytes", "bytes", signature.RangeProofs, t); err != nil {
+		return err
+	}
+
+	appendBytes([]byte("signature"), []byte(AGGREGATE_END), t)
+	appendBytes([]byte("name"), []byte("SignatureRctBulletproofs"), t)
+	return nil
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxHash(t *testing.T) {
+	assert := assert.New(t)
+
+	tx := newTestTx([]uint64{3 * MILLIMOB_TO_PICOMOB, 2 * MILLIMOB_TO_PICOMOB}, []uint64{4 * MILLIMOB_TO_PICOMOB, MILLIMOB_TO_PICOMOB - MINIMUM_FEE}, MINIMUM_FEE, 150)
+	hash, err := tx.Hash()
+	assert.Nil(err)
+	assert.Equal(hash, txHash(tx))
+	assert.Len(hash.String(), 64)
+	assert.NotEqual(txPrefixHash(tx.Prefix), hash[:])
+
+	data, err := json.Marshal(tx)
+	assert.Nil(err)
+	var decoded Tx
+	assert.Nil(json.Unmarshal(data, &decoded))
+	assert.Equal(hash, txHash(&decoded))
+	message, err := TxToProto(tx)
+	assert.Nil(err)
+	converted, err := TxFromProto(message)
+	assert.Nil(err)
+	assert.Equal(hash, txHash(converted))
+
+	keyImages := tx.KeyImages()
+	assert.Len(keyImages, 2)
+	for i, signature := range tx.Signature.RingSignatures {
+		assert.Equal(signature.KeyImage, keyImages[i])
+	}
+	publicKeys := tx.OutputPublicKeys()
+	assert.Len(publicKeys, 2)
+	assert.Equal(tx.Prefix.Outputs[0].PublicKey.String(), publicKeys[0])
+	assert.True(publicKeys[0] < publicKeys[1])
+
+	decoded.Signature.RingSignatures[0].Responses[0] = decoded.Signature.RingSignatures[0].Responses[1]
+	assert.NotEqual(hash, txHash(&decoded))
+	converted.Prefix.Fee++
+	assert.NotEqual(hash, txHash(converted))
+
+	unsigned := &Tx{Prefix: tx.Prefix}
+	_, err = unsigned.Hash()
+	assert.NotNil(err)
+	assert.Nil(unsigned.KeyImages())
+
+	// malformed transactions are errors
+	_, err = (&Tx{}).Hash()
+	assert.NotNil(err)
+	assert.Nil((&Tx{}).OutputPublicKeys())
+	decoded.Signature.RingSignatures[0].KeyImage = "zz"
+	_, err = decoded.Hash()
+	assert.NotNil(err)
+	decoded.Signature.RingSignatures[0] = nil
+	_, err = decoded.Hash()
+	assert.NotNil(err)
+}
+
+func txHash(tx *Tx) TxHash {
+	hash, err := tx.Hash()
+	if err != nil {
+		panic(err)
+	}
+	return hash
+}
+
+func txPrefixHash(prefix *TxPrefix) []byte {
+	hash, err := HashOfTxPrefix(prefix)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+}
+
+func txOutLeaf(txOut *TxOut) TxOutMembershipHash {
+	leaf, err := HashOfTxOut(txOut)
+	if err != nil {
+		panic(err)
+	}
+	return leaf
+}
+
+// The vectors are regression vectors computed by this implementation, they
+// are not from the Rust implementation nor from a ledger block. They pin the
+// transcript of TxOut and the leaf domain separation against accidental
+// changes, but don't prove them right.
+func TestHashOfTxOut(t *testing.T) {
+	assert := assert.New(t)
+
+	txOut := testRegressionTxOut()
+	hash, err := txOut.Hash()
+	assert.Nil(err)
+	assert.Equal("158829c4e365bf7b17aa6f1d06c9ef539548ec096cca202254a9f81f4f4a967b", hex.EncodeToString(hash[:]))
+	assert.Equal("6f7c1ecf6825aaf7010ddd2267c2e9dee010d2f2102c937bf0cea6236f36ec8a", txOutLeaf(txOut).String())
+	assert.Equal("ffdaaf4305e365c4c30ca1e5fbf4f5e62b081441ee94eb2d0980470b5e705968", hashMerkleNil().String())
+
+	prefix := &TxPrefix{Outputs: []*TxOut{txOut}, Fee: MINIMUM_FEE, TombstoneBlock: 100}
+	prefixHash := txPrefixHash(prefix)
+	txOut.EMemo = bytes.Repeat([]byte{0xab}, EncryptedMemoSize)
+	hash, err = txOut.Hash()
+	assert.Nil(err)
+	assert.Equal("5b9ee8dd14cad0f80a848402d7a6c52a25974745da0f637f2f66bee93fbd5167", hex.EncodeToString(hash[:]))
+	assert.Equal("c3d31cf45ba8776c723b97072595a459cf9d4655c67192402b9133739271697c", txOutLeaf(txOut).String())
+	assert.NotEqual(prefixHash, txPrefixHash(prefix))
+
+	// a malformed e_memo is not hashed as absent
+	txOut.EMemo = EncryptedMemo{0xab}
+	_, err = txOut.Hash()
+	assert.NotNil(err)
+	_, err = HashOfTxOut(txOut)
+	assert.NotNil(err)
+	_, err = HashOfTxPrefix(prefix)
+	assert.NotNil(err)
+	_, err = (&Tx{Prefix: prefix, Signature: &SignatureRctBulletproofs{}}).Hash()
+	assert.NotNil(err)
+
+	txOut.EMemo = nil
+	assert.Equal(prefixHash, txPrefixHash(prefix))
+	txOut.Amount.MaskedValue++
+	assert.NotEqual("6f7c1ecf6825aaf7010ddd2267c2e9dee010d2f2102c937bf0cea6236f36ec8a", txOutLeaf(txOut).String())
+}
+
+func testRegressionTxOut() *TxOut {
+	txOut := &TxOut{
+		Amount:   &Amount{MaskedValue: 12345},
+		EFogHint: make(EncryptedFogHint, EncryptedFogHintSize),
+	}
+	for i := 0; i < 32; i++ {
+		txOut.Amount.Commitment[i] = byte(i)
+		txOut.TargetKey[i] = byte(32 + i)
+		txOut.PublicKey[i] = byte(64 + i)
+	}
+	for i := range txOut.EFogHint {
+		txOut.EFogHint[i] = byte(96 + i)
+	}
+	return txOut
+}
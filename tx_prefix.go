@@ -2,7 +2,7 @@ package api
 
 import (
 	"encoding/binary"
-	"encoding/hex"
+	"fmt"
 	"strconv"
 
 	"github.com/bwesterb/go-ristretto"
@@ -19,10 +19,12 @@ const (
 )
 
 // Convert tx_prefix to merlin transcript
-func HashOfTxPrefix(tx *TxPrefix) []byte {
+func HashOfTxPrefix(tx *TxPrefix) ([]byte, error) {
 	t := merlin.NewTranscript("digestible")
-	appendTxPrefix(tx, t)
-	return t.ExtractBytes([]byte("digest32"), 32)
+	if err := appendTxPrefix("mobilecoin-tx-prefix", tx, t); err != nil {
+		return nil, err
+	}
+	return t.ExtractBytes([]byte("digest32"), 32), nil
 }
 
 // TxIn: append transaction inputs to transcript
@@ -76,14 +78,9 @@ func appendRange(r *Range, t *merlin.Transcript) {
 	appendBytes([]byte("name"), []byte("Range"), t)
 }
 
-func appendHash(hash string, t *merlin.Transcript) {
+func appendHash(hash TxOutMembershipHash, t *merlin.Transcript) {
 	appendBytes([]byte("hash"), []byte("prim"), t)
-
-	buf, err := hex.DecodeString(hash)
-	if err != nil {
-		panic(err)
-	}
-	appendBytes([]byte("bytes"), buf, t)
+	appendBytes([]byte("bytes"), hash[:], t)
 }
 
 func appendElement(element *TxOutMembershipElement, t *merlin.Transcript) {
@@ -109,7 +106,7 @@ func appendElements(elements []*TxOutMembershipElement, t *merlin.Transcript) {
 }
 
 // "Ring" of inputs, one of which is actually being spent.
-func appendRing(outputs []*TxOut, t *merlin.Transcript) {
+func appendRing(outputs []*TxOut, t *merlin.Transcript) error {
 	appendBytes([]byte("ring"), []byte(SEQUENCE), t)
 
 	bytes := make([]byte, 8)
@@ -117,8 +114,11 @@ func appendRing(outputs []*TxOut, t *merlin.Transcript) {
 	appendBytes([]byte("len"), bytes, t)
 
 	for _, output := range outputs {
-		appendTxOut(output, t)
+		if err := appendTxOut("", output, t); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 // Proof that each TxOut in `ring` is in the ledger.
@@ -146,18 +146,21 @@ func appendTxOutMembershipProofs(proofs []*TxOutMembershipProof, t *merlin.Trans
 	}
 }
 
-func appendTxIn(in *TxIn, t *merlin.Transcript) {
+func appendTxIn(in *TxIn, t *merlin.Transcript) error {
 	appendBytes([]byte(""), []byte(AGGREGATE), t)
 	appendBytes([]byte("name"), []byte("TxIn"), t)
 
-	appendRing(in.Ring, t)
+	if err := appendRing(in.Ring, t); err != nil {
+		return err
+	}
 	appendTxOutMembershipProofs(in.Proofs, t)
 
 	appendBytes([]byte(""), []byte(AGGREGATE_END), t)
 	appendBytes([]byte("name"), []byte("TxIn"), t)
+	return nil
 }
 
-func appendInputs(inputs []*TxIn, t *merlin.Transcript) {
+func appendInputs(inputs []*TxIn, t *merlin.Transcript) error {
 	appendBytes([]byte("inputs"), []byte(SEQUENCE), t)
 
 	bytes := make([]byte, 8)
@@ -165,20 +168,19 @@ func appendInputs(inputs []*TxIn, t *merlin.Transcript) {
 	appendBytes([]byte("len"), bytes, t)
 
 	for _, input := range inputs {
-		appendTxIn(input, t)
+		if err := appendTxIn(input, t); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 // TxOut: append tx out to transcript
 
 // Append TxOut Amount
-func appendCommitment(commitment string, t *merlin.Transcript) {
-	buf, err := hex.DecodeString(commitment)
-	if err != nil {
-		panic(err)
-	}
+func appendCommitment(commitment CompressedRistretto, t *merlin.Transcript) {
 	appendBytes([]byte("commitment"), []byte(PRIMITIVE), t)
-	appendBytes([]byte("ristretto"), buf, t)
+	appendBytes([]byte("ristretto"), commitment[:], t)
 }
 
 func appendMaskedValue(value MaskedValue, t *merlin.Transcript) {
@@ -201,49 +203,54 @@ func appendAmount(amount *Amount, t *merlin.Transcript) {
 }
 
 // Append TxOut TargetKey
-func appendTargetKey(key string, t *merlin.Transcript) {
-	buf, err := hex.DecodeString(key)
-	if err != nil {
-		panic(err)
-	}
+func appendTargetKey(key CompressedRistretto, t *merlin.Transcript) {
 	appendBytes([]byte("target_key"), []byte(PRIMITIVE), t)
-	appendBytes([]byte("ristretto"), buf, t)
+	appendBytes([]byte("ristretto"), key[:], t)
 }
 
 // Append TxOut PublicKey
-func appendPublicKey(key string, t *merlin.Transcript) {
-	buf, err := hex.DecodeString(key)
-	if err != nil {
-		panic(err)
-	}
+func appendPublicKey(key CompressedRistretto, t *merlin.Transcript) {
 	appendBytes([]byte("public_key"), []byte(PRIMITIVE), t)
-	appendBytes([]byte("ristretto"), buf, t)
+	appendBytes([]byte("ristretto"), key[:], t)
 }
 
 // Append TxOut EFogHint
-func appendEFogHint(hint string, t *merlin.Transcript) {
-	buf, err := hex.DecodeString(hint)
-	if err != nil {
-		panic(err)
-	}
+func appendEFogHint(hint EncryptedFogHint, t *merlin.Transcript) {
 	appendBytes([]byte("e_fog_hint"), []byte(PRIMITIVE), t)
-	appendBytes([]byte("bytes"), buf, t)
+	appendBytes([]byte("bytes"), hint, t)
 }
 
-func appendTxOut(txOut *TxOut, t *merlin.Transcript) {
-	appendBytes([]byte(""), []byte(AGGREGATE), t)
+// Append TxOut EMemo, it is an Option omitted when None
+func appendEMemo(memo EncryptedMemo, t *merlin.Transcript) error {
+	if len(memo) == 0 {
+		return nil
+	}
+	if len(memo) != EncryptedMemoSize {
+		return fmt.Errorf("Invalid EncryptedMemo length %d", len(memo))
+	}
+	appendBytes([]byte("e_memo"), []byte(PRIMITIVE), t)
+	appendBytes([]byte("bytes"), memo, t)
+	return nil
+}
+
+func appendTxOut(context string, txOut *TxOut, t *merlin.Transcript) error {
+	appendBytes([]byte(context), []byte(AGGREGATE), t)
 	appendBytes([]byte("name"), []byte("TxOut"), t)
 
 	appendAmount(txOut.Amount, t)
 	appendTargetKey(txOut.TargetKey, t)
 	appendPublicKey(txOut.PublicKey, t)
 	appendEFogHint(txOut.EFogHint, t)
+	if err := appendEMemo(txOut.EMemo, t); err != nil {
+		return err
+	}
 
-	appendBytes([]byte(""), []byte(AGGREGATE_END), t)
+	appendBytes([]byte(context), []byte(AGGREGATE_END), t)
 	appendBytes([]byte("name"), []byte("TxOut"), t)
+	return nil
 }
 
-func appendOutputs(outputs []*TxOut, t *merlin.Transcript) {
+func appendOutputs(outputs []*TxOut, t *merlin.Transcript) error {
 	appendBytes([]byte("outputs"), []byte(SEQUENCE), t)
 
 	bytes := make([]byte, 8)
@@ -251,8 +258,11 @@ func appendOutputs(outputs []*TxOut, t *merlin.Transcript) {
 	appendBytes([]byte("len"), bytes, t)
 
 	for _, output := range outputs {
-		appendTxOut(output, t)
+		if err := appendTxOut("", output, t); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 // Fee: append fee to transcript
@@ -273,17 +283,22 @@ func appendTombstoneBlock(tombstone uint64, t *merlin.Transcript) {
 	appendBytes([]byte("uint"), bytes, t)
 }
 
-func appendTxPrefix(tx *TxPrefix, t *merlin.Transcript) {
-	appendBytes([]byte("mobilecoin-tx-prefix"), []byte(AGGREGATE), t)
+func appendTxPrefix(context string, tx *TxPrefix, t *merlin.Transcript) error {
+	appendBytes([]byte(context), []byte(AGGREGATE), t)
 	appendBytes([]byte("name"), []byte("TxPrefix"), t)
 
-	appendInputs(tx.Inputs, t)
-	appendOutputs(tx.Outputs, t)
+	if err := appendInputs(tx.Inputs, t); err != nil {
+		return err
+	}
+	if err := appendOutputs(tx.Outputs, t); err != nil {
+		return err
+	}
 	appendFee(uint64(tx.Fee), t)
 	appendTombstoneBlock(uint64(tx.TombstoneBlock), t)
 
-	appendBytes([]byte("mobilecoin-tx-prefix"), []byte(AGGREGATE_END), t)
+	appendBytes([]byte(context), []byte(AGGREGATE_END), t)
 	appendBytes([]byte("name"), []byte("TxPrefix"), t)
+	return nil
 }
 
 func appendInt64(label string, i uint64, t *merlin.Transcript) {
@@ -1,8 +1,14 @@
 package api
 
 import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"strconv"
+	"sync"
 
+	"github.com/bwesterb/go-ristretto"
 	account "github.com/jadeydi/mobilecoin-account"
 )
 
@@ -66,17 +72,250 @@ func (tv *TombstoneValue) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// CompressedRistretto is the 32 bytes encoding of a ristretto point, hex in
+// JSON. Comparing two of them compares their hex.
+type CompressedRistretto [32]byte
+
+func (c CompressedRistretto) String() string {
+	return hex.EncodeToString(c[:])
+}
+
+func (c CompressedRistretto) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(c.String())), nil
+}
+
+func (c *CompressedRistretto) UnmarshalJSON(data []byte) error {
+	return unmarshalFixedHex(data, c[:], "CompressedRistretto")
+}
+
+// Less orders as the hex strings do, it is the order of rings and outputs.
+func (c CompressedRistretto) Less(o CompressedRistretto) bool {
+	for i := range c {
+		if c[i] != o[i] {
+			return c[i] < o[i]
+		}
+	}
+	return false
+}
+
+// Point decodes c, it rejects non canonical encodings.
+func (c CompressedRistretto) Point() (*ristretto.Point, error) {
+	return pointFromBytes(c[:])
+}
+
+// decodedPoint caches the point of a CompressedRistretto inside the TxOut or
+// Amount holding it, it is decoded on first use only. A value copied and
+// changed afterwards no longer matches bytes and is decoded each time.
+type decodedPoint struct {
+	once  sync.Once
+	bytes CompressedRistretto
+	point *ristretto.Point
+	err   error
+}
+
+func newDecodedPoint(c CompressedRistretto) *decodedPoint {
+	return &decodedPoint{bytes: c}
+}
+
+func (d *decodedPoint) decode(c CompressedRistretto) (*ristretto.Point, error) {
+	if d == nil || d.bytes != c {
+		return c.Point()
+	}
+	d.once.Do(func() {
+		d.point, d.err = c.Point()
+	})
+	if d.err != nil {
+		return nil, d.err
+	}
+	// a copy, callers may use it as a receiver
+	var p ristretto.Point
+	return p.Set(d.point), nil
+}
+
+// CompressedRistrettoFromPoint is the encoding of p.
+func CompressedRistrettoFromPoint(p *ristretto.Point) CompressedRistretto {
+	var c CompressedRistretto
+	copy(c[:], p.Bytes())
+	return c
+}
+
+// EncryptedFogHint is hex in JSON, it is either empty or of
+// EncryptedFogHintSize.
+type EncryptedFogHint []byte
+
+func (h EncryptedFogHint) String() string {
+	return hex.EncodeToString(h)
+}
+
+func (h EncryptedFogHint) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(h.String())), nil
+}
+
+func (h *EncryptedFogHint) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	dd, err := strconv.Unquote(string(data))
+	if err != nil {
+		return err
+	}
+	buf, err := hex.DecodeString(dd)
+	if err != nil {
+		return fmt.Errorf("Invalid EncryptedFogHint %s", dd)
+	}
+	if len(buf) != 0 && len(buf) != EncryptedFogHintSize {
+		return fmt.Errorf("Invalid EncryptedFogHint length %d", len(buf))
+	}
+	*h = buf
+	return nil
+}
+
+// EncryptedMemo is hex in JSON, it is either empty, the memo is None, or of
+// EncryptedMemoSize.
+type EncryptedMemo []byte
+
+const EncryptedMemoSize = 66
+
+func (m EncryptedMemo) String() string {
+	return hex.EncodeToString(m)
+}
+
+func (m EncryptedMemo) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(m.String())), nil
+}
+
+func (m *EncryptedMemo) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	dd, err := strconv.Unquote(string(data))
+	if err != nil {
+		return err
+	}
+	buf, err := hex.DecodeString(dd)
+	if err != nil {
+		return fmt.Errorf("Invalid EncryptedMemo %s", dd)
+	}
+	if len(buf) != 0 && len(buf) != EncryptedMemoSize {
+		return fmt.Errorf("Invalid EncryptedMemo length %d", len(buf))
+	}
+	if len(buf) == 0 {
+		buf = nil
+	}
+	*m = buf
+	return nil
+}
+
+type TxOutMembershipHash [32]byte
+
+func (h TxOutMembershipHash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+func (h TxOutMembershipHash) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(h.String())), nil
+}
+
+func (h *TxOutMembershipHash) UnmarshalJSON(data []byte) error {
+	return unmarshalFixedHex(data, h[:], "TxOutMembershipHash")
+}
+
+// unmarshalFixedHex rejects null, it would leave dst all zero, which is a
+// valid encoding.
+func unmarshalFixedHex(data []byte, dst []byte, name string) error {
+	if string(data) == "null" {
+		return fmt.Errorf("Invalid %s null", name)
+	}
+	dd, err := strconv.Unquote(string(data))
+	if err != nil {
+		return err
+	}
+	buf, err := hex.DecodeString(dd)
+	if err != nil || len(buf) != len(dst) {
+		return fmt.Errorf("Invalid %s %s", name, dd)
+	}
+	copy(dst, buf)
+	return nil
+}
+
 type Amount struct {
-	Commitment  string      `json:"commitment"`
-	MaskedValue MaskedValue `json:"masked_value"`
+	Commitment  CompressedRistretto `json:"commitment"`
+	MaskedValue MaskedValue         `json:"masked_value"`
+
+	decodedCommitment *decodedPoint
+}
+
+// UnmarshalJSON requires the commitment, a missing one would be all zero.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	type amount Amount
+	v := struct {
+		*amount
+		Commitment *CompressedRistretto `json:"commitment"`
+	}{amount: (*amount)(a)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Commitment == nil {
+		return errors.New("Invalid Amount without commitment")
+	}
+	a.Commitment = *v.Commitment
+	a.decodedCommitment = newDecodedPoint(a.Commitment)
+	return nil
+}
+
+func (a *Amount) CommitmentPoint() (*ristretto.Point, error) {
+	return a.decodedCommitment.decode(a.Commitment)
 }
 
 type TxOut struct {
-	Amount    *Amount `json:"amount"`
-	TargetKey string  `json:"target_key"`
-	PublicKey string  `json:"public_key"`
-	EFogHint  string  `json:"e_fog_hint"`
-	EMemo     string  `json:"e_memo"`
+	Amount    *Amount             `json:"amount"`
+	TargetKey CompressedRistretto `json:"target_key"`
+	PublicKey CompressedRistretto `json:"public_key"`
+	EFogHint  EncryptedFogHint    `json:"e_fog_hint"`
+	EMemo     EncryptedMemo       `json:"e_memo"`
+
+	decodedTargetKey *decodedPoint
+	decodedPublicKey *decodedPoint
+}
+
+func (o *TxOut) cachePoints() {
+	o.decodedTargetKey = newDecodedPoint(o.TargetKey)
+	o.decodedPublicKey = newDecodedPoint(o.PublicKey)
+}
+
+// UnmarshalJSON requires the target and public keys, missing ones would be
+// all zero.
+func (o *TxOut) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	type txOut TxOut
+	v := struct {
+		*txOut
+		TargetKey *CompressedRistretto `json:"target_key"`
+		PublicKey *CompressedRistretto `json:"public_key"`
+	}{txOut: (*txOut)(o)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.TargetKey == nil || v.PublicKey == nil {
+		return errors.New("Invalid TxOut without target_key or public_key")
+	}
+	o.TargetKey = *v.TargetKey
+	o.PublicKey = *v.PublicKey
+	o.cachePoints()
+	return nil
+}
+
+func (o *TxOut) TargetKeyPoint() (*ristretto.Point, error) {
+	return o.decodedTargetKey.decode(o.TargetKey)
+}
+
+func (o *TxOut) PublicKeyPoint() (*ristretto.Point, error) {
+	return o.decodedPublicKey.decode(o.PublicKey)
 }
 
 type Range struct {
@@ -85,8 +324,8 @@ type Range struct {
 }
 
 type TxOutMembershipElement struct {
-	Range *Range `json:"range"`
-	Hash  string `json:"hash"`
+	Range *Range              `json:"range"`
+	Hash  TxOutMembershipHash `json:"hash"`
 }
 
 type TxOutMembershipProof struct {
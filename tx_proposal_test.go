@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxOutJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	_, address := newTestAccount()
+	output, publicKey, err := CreateOutput(MILLIMOB_TO_PICOMOB, address, 0)
+	assert.Nil(err)
+	txOut := output.Output
+	assert.Equal(publicKey, txOut.PublicKey.String())
+
+	data, err := json.Marshal(txOut)
+	assert.Nil(err)
+	var fields map[string]interface{}
+	assert.Nil(json.Unmarshal(data, &fields))
+	assert.Equal(publicKey, fields["public_key"])
+	assert.Equal(txOut.TargetKey.String(), fields["target_key"])
+	assert.Len(fields["e_fog_hint"], 2*EncryptedFogHintSize)
+	assert.Equal(txOut.Amount.Commitment.String(), fields["amount"].(map[string]interface{})["commitment"])
+
+	var decoded TxOut
+	assert.Nil(json.Unmarshal(data, &decoded))
+	assert.Equal(txOut, &decoded)
+	assert.Nil(decoded.EMemo)
+	point, err := decoded.TargetKeyPoint()
+	assert.Nil(err)
+	assert.Equal(txOut.TargetKey, CompressedRistrettoFromPoint(point))
+
+	// the points are decoded once, a changed copy is decoded again
+	assert.NotNil(decoded.decodedTargetKey.point)
+	assert.Nil(decoded.decodedPublicKey.point)
+	point.Add(point, point)
+	again, err := decoded.TargetKeyPoint()
+	assert.Nil(err)
+	assert.Equal(txOut.TargetKey, CompressedRistrettoFromPoint(again))
+	changed := decoded
+	changed.TargetKey = decoded.PublicKey
+	point, err = changed.TargetKeyPoint()
+	assert.Nil(err)
+	assert.Equal(decoded.PublicKey, CompressedRistrettoFromPoint(point))
+	changed.TargetKey = CompressedRistretto{0xff}
+	_, err = changed.TargetKeyPoint()
+	assert.NotNil(err)
+
+	memo := strings.Repeat("ab", EncryptedMemoSize)
+	withMemo := strings.Replace(string(data), `"e_memo":""`, `"e_memo":"`+memo+`"`, 1)
+	assert.Nil(json.Unmarshal([]byte(withMemo), &decoded))
+	assert.Equal(memo, decoded.EMemo.String())
+
+	for _, invalid := range []string{
+		strings.Replace(string(data), publicKey, "zz"+publicKey[2:], 1),
+		strings.Replace(string(data), publicKey, publicKey[2:], 1),
+		strings.Replace(string(data), txOut.EFogHint.String(), txOut.EFogHint.String()[2:], 1),
+		strings.Replace(string(data), `"`+publicKey+`"`, "null", 1),
+		strings.Replace(string(data), `"public_key"`, `"other_key"`, 1),
+		strings.Replace(string(data), `"commitment"`, `"other_commitment"`, 1),
+		strings.Replace(string(data), `"e_memo":""`, `"e_memo":"abab"`, 1),
+		strings.Replace(string(data), `"e_memo":""`, `"e_memo":"zz"`, 1),
+	} {
+		assert.NotNil(json.Unmarshal([]byte(invalid), &TxOut{}))
+	}
+
+	var element TxOutMembershipElement
+	assert.Nil(json.Unmarshal([]byte(`{"range":{"from":"0","to":"1"},"hash":"`+publicKey+`"}`), &element))
+	assert.Equal(publicKey, element.Hash.String())
+	assert.NotNil(json.Unmarshal([]byte(`{"range":{"from":"0","to":"1"},"hash":"00"}`), &element))
+	assert.NotNil(json.Unmarshal([]byte(`{"range":{"from":"0","to":"1"},"hash":null}`), &element))
+
+	var a, b CompressedRistretto
+	b[31] = 1
+	assert.True(a.Less(b))
+	assert.False(b.Less(a))
+	assert.False(a.Less(a))
+	_, err = CompressedRistretto{0xff}.Point()
+	assert.NotNil(err)
+}
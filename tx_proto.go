@@ -0,0 +1,347 @@
+package api
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/jadeydi/mobilecoin-account/block"
+)
+
+// TxToProto converts tx to the protobuf type submitted to consensus, every
+// hex field is decoded and checked for its length.
+func TxToProto(tx *Tx) (*block.Tx, error) {
+	if tx == nil || tx.Prefix == nil {
+		return nil, errors.New("Invalid tx, prefix required")
+	}
+	prefix := &block.TxPrefix{
+		Fee:            uint64(tx.Prefix.Fee),
+		TombstoneBlock: uint64(tx.Prefix.TombstoneBlock),
+	}
+	for i, input := range tx.Prefix.Inputs {
+		if input == nil {
+			return nil, fmt.Errorf("Invalid tx input %d", i)
+		}
+		in := &block.TxIn{}
+		for _, output := range input.Ring {
+			o, err := TxOutToProto(output)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid tx input %d %w", i, err)
+			}
+			in.Ring = append(in.Ring, o)
+		}
+		for _, proof := range input.Proofs {
+			p, err := TxOutMembershipProofToProto(proof)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid tx input %d %w", i, err)
+			}
+			in.Proofs = append(in.Proofs, p)
+		}
+		prefix.Inputs = append(prefix.Inputs, in)
+	}
+	for i, output := range tx.Prefix.Outputs {
+		o, err := TxOutToProto(output)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid tx output %d %w", i, err)
+		}
+		prefix.Outputs = append(prefix.Outputs, o)
+	}
+
+	result := &block.Tx{Prefix: prefix}
+	if tx.Signature != nil {
+		signature, err := SignatureRctBulletproofsToProto(tx.Signature)
+		if err != nil {
+			return nil, err
+		}
+		result.Signature = signature
+	}
+	return result, nil
+}
+
+// TxFromProto is the inverse of TxToProto.
+func TxFromProto(tx *block.Tx) (*Tx, error) {
+	if tx == nil || tx.Prefix == nil {
+		return nil, errors.New("Invalid tx, prefix required")
+	}
+	prefix := &TxPrefix{
+		Fee:            FeeValue(tx.Prefix.Fee),
+		TombstoneBlock: TombstoneValue(tx.Prefix.TombstoneBlock),
+	}
+	for i, input := range tx.Prefix.Inputs {
+		if input == nil {
+			return nil, fmt.Errorf("Invalid tx input %d", i)
+		}
+		in := &TxIn{}
+		for _, output := range input.Ring {
+			o, err := TxOutFromProto(output)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid tx input %d %w", i, err)
+			}
+			in.Ring = append(in.Ring, o)
+		}
+		for _, proof := range input.Proofs {
+			p, err := TxOutMembershipProofFromProto(proof)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid tx input %d %w", i, err)
+			}
+			in.Proofs = append(in.Proofs, p)
+		}
+		prefix.Inputs = append(prefix.Inputs, in)
+	}
+	for i, output := range tx.Prefix.Outputs {
+		o, err := TxOutFromProto(output)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid tx output %d %w", i, err)
+		}
+		prefix.Outputs = append(prefix.Outputs, o)
+	}
+
+	result := &Tx{Prefix: prefix}
+	if tx.Signature != nil {
+		signature, err := SignatureRctBulletproofsFromProto(tx.Signature)
+		if err != nil {
+			return nil, err
+		}
+		result.Signature = signature
+	}
+	return result, nil
+}
+
+// TxOutToProto fails on an EMemo, block.TxOut has no field for it.
+func TxOutToProto(output *TxOut) (*block.TxOut, error) {
+	if output == nil || output.Amount == nil {
+		return nil, errors.New("Invalid TxOut, amount required")
+	}
+	if err := checkTxOutEMemo(output); err != nil {
+		return nil, err
+	}
+	if err := checkFieldSize("TxOut e_fog_hint", output.EFogHint, EncryptedFogHintSize); err != nil {
+		return nil, err
+	}
+	commitment, targetKey, publicKey := output.Amount.Commitment, output.TargetKey, output.PublicKey
+	return &block.TxOut{
+		Amount: &block.Amount{
+			Commitment:  &block.CompressedRistretto{Data: commitment[:]},
+			MaskedValue: uint64(output.Amount.MaskedValue),
+		},
+		TargetKey: &block.CompressedRistretto{Data: targetKey[:]},
+		PublicKey: &block.CompressedRistretto{Data: publicKey[:]},
+		EFogHint:  &block.EncryptedFogHint{Data: append([]byte{}, output.EFogHint...)},
+	}, nil
+}
+
+func TxOutFromProto(output *block.TxOut) (*TxOut, error) {
+	if output == nil || output.Amount == nil {
+		return nil, errors.New("Invalid TxOut, amount required")
+	}
+	result := &TxOut{Amount: &Amount{MaskedValue: MaskedValue(output.Amount.MaskedValue)}}
+	err := copyFixedField("TxOut commitment", result.Amount.Commitment[:], output.Amount.Commitment.GetData())
+	if err != nil {
+		return nil, err
+	}
+	err = copyFixedField("TxOut target_key", result.TargetKey[:], output.TargetKey.GetData())
+	if err != nil {
+		return nil, err
+	}
+	err = copyFixedField("TxOut public_key", result.PublicKey[:], output.PublicKey.GetData())
+	if err != nil {
+		return nil, err
+	}
+	hint := output.EFogHint.GetData()
+	if err := checkFieldSize("TxOut e_fog_hint", hint, EncryptedFogHintSize); err != nil {
+		return nil, err
+	}
+	result.EFogHint = append(EncryptedFogHint{}, hint...)
+	result.Amount.decodedCommitment = newDecodedPoint(result.Amount.Commitment)
+	result.cachePoints()
+	return result, nil
+}
+
+func TxOutMembershipProofToProto(proof *TxOutMembershipProof) (*block.TxOutMembershipProof, error) {
+	if proof == nil {
+		return nil, errors.New("Invalid TxOutMembershipProof")
+	}
+	index, err := strconv.ParseUint(proof.Index, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid TxOutMembershipProof index %s", proof.Index)
+	}
+	highestIndex, err := strconv.ParseUint(proof.HighestIndex, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid TxOutMembershipProof highest_index %s", proof.HighestIndex)
+	}
+	result := &block.TxOutMembershipProof{Index: index, HighestIndex: highestIndex}
+	for i, element := range proof.Elements {
+		if element == nil || element.Range == nil {
+			return nil, fmt.Errorf("Invalid TxOutMembershipProof element %d", i)
+		}
+		from, err := strconv.ParseUint(element.Range.From, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid TxOutMembershipProof element %d from %s", i, element.Range.From)
+		}
+		to, err := strconv.ParseUint(element.Range.To, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid TxOutMembershipProof element %d to %s", i, element.Range.To)
+		}
+		hash := element.Hash
+		result.Elements = append(result.Elements, &block.TxOutMembershipElement{
+			Range: &block.Range{From: from, To: to},
+			Hash:  &block.TxOutMembershipHash{Data: hash[:]},
+		})
+	}
+	return result, nil
+}
+
+func TxOutMembershipProofFromProto(proof *block.TxOutMembershipProof) (*TxOutMembershipProof, error) {
+	if proof == nil {
+		return nil, errors.New("Invalid TxOutMembershipProof")
+	}
+	result := &TxOutMembershipProof{
+		Index:        strconv.FormatUint(proof.Index, 10),
+		HighestIndex: strconv.FormatUint(proof.HighestIndex, 10),
+	}
+	for i, element := range proof.Elements {
+		if element == nil || element.Range == nil {
+			return nil, fmt.Errorf("Invalid TxOutMembershipProof element %d", i)
+		}
+		e := &TxOutMembershipElement{
+			Range: &Range{
+				From: strconv.FormatUint(element.Range.From, 10),
+				To:   strconv.FormatUint(element.Range.To, 10),
+			},
+		}
+		err := copyFixedField("TxOutMembershipProof hash", e.Hash[:], element.Hash.GetData())
+		if err != nil {
+			return nil, err
+		}
+		result.Elements = append(result.Elements, e)
+	}
+	return result, nil
+}
+
+func SignatureRctBulletproofsToProto(signature *SignatureRctBulletproofs) (*block.SignatureRctBulletproofs, error) {
+	if signature == nil {
+		return nil, errors.New("Invalid SignatureRctBulletproofs")
+	}
+	rangeProofs, err := decodeHexField("SignatureRctBulletproofs range_proofs", signature.RangeProofs, 0)
+	if err != nil {
+		return nil, err
+	}
+	result := &block.SignatureRctBulletproofs{RangeProofs: rangeProofs}
+	for i, ring := range signature.RingSignatures {
+		if ring == nil {
+			return nil, fmt.Errorf("Invalid RingMLSAG %d", i)
+		}
+		cZero, err := decodeHexField("RingMLSAG c_zero", ring.CZero, 32)
+		if err != nil {
+			return nil, err
+		}
+		keyImage, err := decodeHexField("RingMLSAG key_image", ring.KeyImage, 32)
+		if err != nil {
+			return nil, err
+		}
+		r := &block.RingMLSAG{
+			CZero:    &block.CurveScalar{Data: cZero},
+			KeyImage: &block.KeyImage{Data: keyImage},
+		}
+		for _, response := range ring.Responses {
+			data, err := decodeHexField("RingMLSAG response", response, 32)
+			if err != nil {
+				return nil, err
+			}
+			r.Responses = append(r.Responses, &block.CurveScalar{Data: data})
+		}
+		result.RingSignatures = append(result.RingSignatures, r)
+	}
+	for _, commitment := range signature.PseudoOutputCommitments {
+		data, err := decodeHexField("SignatureRctBulletproofs pseudo_output_commitment", commitment, 32)
+		if err != nil {
+			return nil, err
+		}
+		result.PseudoOutputCommitments = append(result.PseudoOutputCommitments, &block.CompressedRistretto{Data: data})
+	}
+	return result, nil
+}
+
+func SignatureRctBulletproofsFromProto(signature *block.SignatureRctBulletproofs) (*SignatureRctBulletproofs, error) {
+	if signature == nil {
+		return nil, errors.New("Invalid SignatureRctBulletproofs")
+	}
+	rangeProofs, err := encodeHexField("SignatureRctBulletproofs range_proofs", signature.RangeProofs, 0)
+	if err != nil {
+		return nil, err
+	}
+	result := &SignatureRctBulletproofs{RangeProofs: rangeProofs}
+	for i, ring := range signature.RingSignatures {
+		if ring == nil {
+			return nil, fmt.Errorf("Invalid RingMLSAG %d", i)
+		}
+		cZero, err := encodeHexField("RingMLSAG c_zero", ring.CZero.GetData(), 32)
+		if err != nil {
+			return nil, err
+		}
+		keyImage, err := encodeHexField("RingMLSAG key_image", ring.KeyImage.GetData(), 32)
+		if err != nil {
+			return nil, err
+		}
+		r := &RingMLSAG{CZero: cZero, KeyImage: keyImage}
+		for _, response := range ring.Responses {
+			data, err := encodeHexField("RingMLSAG response", response.GetData(), 32)
+			if err != nil {
+				return nil, err
+			}
+			r.Responses = append(r.Responses, data)
+		}
+		result.RingSignatures = append(result.RingSignatures, r)
+	}
+	for _, commitment := range signature.PseudoOutputCommitments {
+		data, err := encodeHexField("SignatureRctBulletproofs pseudo_output_commitment", commitment.GetData(), 32)
+		if err != nil {
+			return nil, err
+		}
+		result.PseudoOutputCommitments = append(result.PseudoOutputCommitments, data)
+	}
+	return result, nil
+}
+
+// decodeHexField decodes a field of size bytes, or of any non zero size
+// when size is 0.
+func decodeHexField(name, h string, size int) ([]byte, error) {
+	data, err := hex.DecodeString(h)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s hex %s", name, h)
+	}
+	if err := checkFieldSize(name, data, size); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func encodeHexField(name string, data []byte, size int) (string, error) {
+	if err := checkFieldSize(name, data, size); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
+
+func copyFixedField(name string, dst, data []byte) error {
+	if err := checkFieldSize(name, data, len(dst)); err != nil {
+		return err
+	}
+	copy(dst, data)
+	return nil
+}
+
+func checkFieldSize(name string, data []byte, size int) error {
+	if len(data) == 0 || (size > 0 && len(data) != size) {
+		return fmt.Errorf("Invalid %s length %d", name, len(data))
+	}
+	return nil
+}
+
+func checkTxOutEMemo(output *TxOut) error {
+	if output != nil && len(output.EMemo) != 0 {
+		return errors.New("Invalid TxOut e_memo, not supported by block.TxOut")
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jadeydi/mobilecoin-account/block"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestTxProto(t *testing.T) {
+	assert := assert.New(t)
+
+	tx := newTestTx([]uint64{3 * MILLIMOB_TO_PICOMOB, 2 * MILLIMOB_TO_PICOMOB}, []uint64{4 * MILLIMOB_TO_PICOMOB, MILLIMOB_TO_PICOMOB - MINIMUM_FEE}, MINIMUM_FEE, 150)
+	tx.Prefix.Inputs[0].Proofs[0].Elements = []*TxOutMembershipElement{
+		{Range: &Range{From: "0", To: "7"}, Hash: TxOutMembershipHash(tx.Prefix.Outputs[0].PublicKey)},
+	}
+	message, err := TxToProto(tx)
+	assert.Nil(err)
+	assert.Len(message.Prefix.Inputs, 2)
+	assert.Equal(uint64(MINIMUM_FEE), message.Prefix.Fee)
+	assert.Equal(uint64(7), message.Prefix.Inputs[0].Proofs[0].Elements[0].Range.To)
+
+	data, err := proto.Marshal(message)
+	assert.Nil(err)
+	decoded := &block.Tx{}
+	assert.Nil(proto.Unmarshal(data, decoded))
+	result, err := TxFromProto(decoded)
+	assert.Nil(err)
+	expected, _ := json.Marshal(tx)
+	actual, _ := json.Marshal(result)
+	assert.Equal(string(expected), string(actual))
+
+	again, err := TxToProto(result)
+	assert.Nil(err)
+	assert.True(proto.Equal(message, again))
+
+	output, err := TxOutFromProto(message.Prefix.Outputs[0])
+	assert.Nil(err)
+	expected, _ = json.Marshal(tx.Prefix.Outputs[0])
+	actual, _ = json.Marshal(output)
+	assert.Equal(string(expected), string(actual))
+
+	for _, mutate := range []func(tx *Tx){
+		func(tx *Tx) { tx.Prefix.Outputs[0].EFogHint = tx.Prefix.Outputs[0].EFogHint[1:] },
+		func(tx *Tx) { tx.Prefix.Outputs[0].EMemo = make(EncryptedMemo, EncryptedMemoSize) },
+		func(tx *Tx) { tx.Prefix.Inputs[0].Ring[0].Amount = nil },
+		func(tx *Tx) { tx.Prefix.Inputs[0].Proofs[0].Index = "-1" },
+		func(tx *Tx) { tx.Signature.RingSignatures[0].KeyImage = "" },
+		func(tx *Tx) { tx.Signature.RingSignatures[0].Responses[1] = "zz" },
+		func(tx *Tx) { tx.Signature.RangeProofs = "" },
+	} {
+		invalid, err := TxFromProto(message)
+		assert.Nil(err)
+		mutate(invalid)
+		_, err = TxToProto(invalid)
+		assert.NotNil(err)
+	}
+	_, err = TxToProto(&Tx{})
+	assert.NotNil(err)
+
+	for _, mutate := range []func(tx *block.Tx){
+		func(tx *block.Tx) { tx.Prefix.Outputs[0].EFogHint.Data = tx.Prefix.Outputs[0].EFogHint.Data[1:] },
+		func(tx *block.Tx) { tx.Prefix.Outputs[0].TargetKey = nil },
+		func(tx *block.Tx) { tx.Prefix.Inputs[0].Proofs[0].Elements[0].Hash.Data = nil },
+		func(tx *block.Tx) { tx.Signature.RingSignatures[0].CZero.Data = make([]byte, 33) },
+		func(tx *block.Tx) { tx.Signature.RangeProofs = nil },
+	} {
+		invalid := proto.Clone(message).(*block.Tx)
+		mutate(invalid)
+		_, err = TxFromProto(invalid)
+		assert.NotNil(err)
+	}
+	_, err = TxFromProto(&block.Tx{})
+	assert.NotNil(err)
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// EstimateTxSize assumes membership proofs in a ledger of 2^32 outputs,
+	// the first element of a proof is the leaf itself.
+	estimatedLedgerDepth = 32
+	estimatedLedgerIndex = math.MaxUint32
+)
+
+// EstimateTxSize is the protobuf encoded size of a Tx with numInputs rings
+// of ringSize, before it is built. It is an upper bound for ledgers of less
+// than 2^32 outputs.
+func EstimateTxSize(numInputs, numOutputs, ringSize int) int {
+	point := strings.Repeat("00", 32)
+	output := &TxOut{
+		Amount:   &Amount{MaskedValue: math.MaxUint64},
+		EFogHint: make(EncryptedFogHint, EncryptedFogHintSize),
+	}
+	index := strconv.FormatUint(estimatedLedgerIndex, 10)
+	element := &TxOutMembershipElement{Range: &Range{From: index, To: index}}
+	proof := &TxOutMembershipProof{Index: index, HighestIndex: index}
+	for i := 0; i <= estimatedLedgerDepth; i++ {
+		proof.Elements = append(proof.Elements, element)
+	}
+	input := &TxIn{}
+	signature := &RingMLSAG{CZero: point, KeyImage: point}
+	for i := 0; i < ringSize; i++ {
+		input.Ring = append(input.Ring, output)
+		input.Proofs = append(input.Proofs, proof)
+		signature.Responses = append(signature.Responses, point, point)
+	}
+
+	tx := &Tx{
+		Prefix:    &TxPrefix{Fee: math.MaxUint64, TombstoneBlock: math.MaxUint64},
+		Signature: &SignatureRctBulletproofs{},
+	}
+	for i := 0; i < numInputs; i++ {
+		tx.Prefix.Inputs = append(tx.Prefix.Inputs, input)
+		tx.Signature.RingSignatures = append(tx.Signature.RingSignatures, signature)
+		tx.Signature.PseudoOutputCommitments = append(tx.Signature.PseudoOutputCommitments, point)
+	}
+	for i := 0; i < numOutputs; i++ {
+		tx.Prefix.Outputs = append(tx.Prefix.Outputs, output)
+	}
+	// A, S, T1, T2, t_x, t_x_blinding, e_blinding, the L and R vectors of
+	// the inner product proof, a and b
+	logMN := 6
+	for m := 1; m < numInputs+numOutputs; m <<= 1 {
+		logMN++
+	}
+	tx.Signature.RangeProofs = strings.Repeat("00", (9+2*logMN)*32)
+	return tx.encodedSize()
+}
+
+// EncodedSize is the size of the protobuf encoding of the Tx, every field
+// holding hex is counted by its decoded length. It fails on an output with
+// an EMemo, as TxToProto does, block.TxOut has no field for it.
+func (tx *Tx) EncodedSize() (int, error) {
+	if tx.Prefix != nil {
+		for _, input := range tx.Prefix.Inputs {
+			for _, output := range input.Ring {
+				if err := checkTxOutEMemo(output); err != nil {
+					return 0, err
+				}
+			}
+		}
+		for _, output := range tx.Prefix.Outputs {
+			if err := checkTxOutEMemo(output); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return tx.encodedSize(), nil
+}
+
+func (tx *Tx) encodedSize() int {
+	var size int
+	if tx.Prefix != nil {
+		size += sizeMessageField(1, tx.Prefix.encodedSize())
+	}
+	if tx.Signature != nil {
+		size += sizeMessageField(2, tx.Signature.encodedSize())
+	}
+	return size
+}
+
+func (prefix *TxPrefix) encodedSize() int {
+	var size int
+	for _, input := range prefix.Inputs {
+		size += sizeMessageField(1, input.encodedSize())
+	}
+	for _, output := range prefix.Outputs {
+		size += sizeMessageField(2, output.encodedSize())
+	}
+	size += sizeVarintField(3, uint64(prefix.Fee))
+	size += sizeVarintField(4, uint64(prefix.TombstoneBlock))
+	return size
+}
+
+func (input *TxIn) encodedSize() int {
+	var size int
+	for _, output := range input.Ring {
+		size += sizeMessageField(1, output.encodedSize())
+	}
+	for _, proof := range input.Proofs {
+		size += sizeMessageField(2, proof.encodedSize())
+	}
+	return size
+}
+
+func (output *TxOut) encodedSize() int {
+	var size int
+	if output.Amount != nil {
+		amount := sizeMessageField(1, sizeBytesField(1, len(output.Amount.Commitment)))
+		if output.Amount.MaskedValue != 0 {
+			amount += protowire.SizeTag(2) + protowire.SizeFixed64()
+		}
+		size += sizeMessageField(1, amount)
+	}
+	size += sizeMessageField(2, sizeBytesField(1, len(output.TargetKey)))
+	size += sizeMessageField(3, sizeBytesField(1, len(output.PublicKey)))
+	size += sizeMessageField(4, sizeBytesField(1, len(output.EFogHint)))
+	return size
+}
+
+func (proof *TxOutMembershipProof) encodedSize() int {
+	size := sizeVarintField(1, parseUint(proof.Index))
+	size += sizeVarintField(2, parseUint(proof.HighestIndex))
+	for _, element := range proof.Elements {
+		var e int
+		if element.Range != nil {
+			r := sizeVarintField(1, parseUint(element.Range.From))
+			r += sizeVarintField(2, parseUint(element.Range.To))
+			e += sizeMessageField(1, r)
+		}
+		e += sizeMessageField(2, sizeBytesField(1, len(element.Hash)))
+		size += sizeMessageField(3, e)
+	}
+	return size
+}
+
+func (signature *SignatureRctBulletproofs) encodedSize() int {
+	var size int
+	for _, ring := range signature.RingSignatures {
+		r := sizeMessageField(1, sizeHexField(1, ring.CZero))
+		for _, response := range ring.Responses {
+			r += sizeMessageField(2, sizeHexField(1, response))
+		}
+		r += sizeMessageField(3, sizeHexField(1, ring.KeyImage))
+		size += sizeMessageField(1, r)
+	}
+	for _, commitment := range signature.PseudoOutputCommitments {
+		size += sizeMessageField(2, sizeHexField(1, commitment))
+	}
+	size += sizeHexField(3, signature.RangeProofs)
+	return size
+}
+
+// sizeMessageField is the size of an embedded message field, which is
+// encoded even when it is empty.
+func sizeMessageField(num protowire.Number, n int) int {
+	return protowire.SizeTag(num) + protowire.SizeBytes(n)
+}
+
+// sizeHexField is the size of a bytes field given in hex, empty bytes are
+// not encoded.
+func sizeHexField(num protowire.Number, h string) int {
+	return sizeBytesField(num, len(h)/2)
+}
+
+func sizeBytesField(num protowire.Number, n int) int {
+	if n == 0 {
+		return 0
+	}
+	return protowire.SizeTag(num) + protowire.SizeBytes(n)
+}
+
+func sizeVarintField(num protowire.Number, v uint64) int {
+	if v == 0 {
+		return 0
+	}
+	return protowire.SizeTag(num) + protowire.SizeVarint(v)
+}
+
+func parseUint(s string) uint64 {
+	v, _ := strconv.ParseUint(s, 10, 64)
+	return v
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestTxEncodedSize(t *testing.T) {
+	assert := assert.New(t)
+
+	tx := newTestTx([]uint64{3 * MILLIMOB_TO_PICOMOB, 2 * MILLIMOB_TO_PICOMOB}, []uint64{4 * MILLIMOB_TO_PICOMOB, MILLIMOB_TO_PICOMOB - MINIMUM_FEE}, MINIMUM_FEE, 150)
+	message, err := TxToProto(tx)
+	assert.Nil(err)
+	assert.Equal(proto.Size(message), txEncodedSize(tx))
+
+	// membership proofs of a ledger of 2^32 outputs
+	for _, input := range tx.Prefix.Inputs {
+		for i, proof := range input.Proofs {
+			proof.Index = strconv.FormatUint(estimatedLedgerIndex-uint64(i), 10)
+			proof.HighestIndex = strconv.FormatUint(estimatedLedgerIndex, 10)
+			for j := 0; j <= estimatedLedgerDepth; j++ {
+				proof.Elements = append(proof.Elements, &TxOutMembershipElement{
+					Range: &Range{From: strconv.FormatUint(estimatedLedgerIndex>>j<<j, 10), To: proof.HighestIndex},
+					Hash:  TxOutMembershipHash(tx.Prefix.Outputs[0].PublicKey),
+				})
+			}
+		}
+	}
+	size := txEncodedSize(tx)
+	message, err = TxToProto(tx)
+	assert.Nil(err)
+	assert.Equal(proto.Size(message), size)
+
+	estimate := EstimateTxSize(2, 2, RING_SIZE)
+	assert.True(estimate >= size)
+	assert.True(estimate-size < 256)
+	assert.True(EstimateTxSize(MAX_INPUTS, MAX_OUTPUTS, RING_SIZE) > EstimateTxSize(MAX_INPUTS, 2, RING_SIZE))
+	assert.True(EstimateTxSize(2, 2, RING_SIZE+1) > estimate)
+
+	assert.Equal(0, txEncodedSize(&Tx{}))
+
+	// a Tx TxToProto refuses has no size
+	tx.Prefix.Outputs[0].EMemo = make(EncryptedMemo, EncryptedMemoSize)
+	_, err = tx.EncodedSize()
+	assert.NotNil(err)
+	tx.Prefix.Outputs[0].EMemo = nil
+	tx.Prefix.Inputs[0].Ring[0].EMemo = make(EncryptedMemo, EncryptedMemoSize)
+	_, err = tx.EncodedSize()
+	assert.NotNil(err)
+}
+
+func txEncodedSize(tx *Tx) int {
+	size, err := tx.EncodedSize()
+	if err != nil {
+		panic(err)
+	}
+	return size
+}
@@ -0,0 +1,323 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bwesterb/go-ristretto"
+)
+
+const (
+	MAX_OUTPUTS = 16
+	MINIMUM_FEE = 400_000_000
+)
+
+// https://github.com/mobilecoinfoundation/mobilecoin/blob/master/transaction/core/src/validation/error.rs
+type ValidationRule int
+
+const (
+	NoInputs ValidationRule = iota + 1
+	TooManyInputs
+	NoOutputs
+	TooManyOutputs
+	InsufficientRingSize
+	ExcessiveRingSize
+	DuplicateRingElements
+	UnsortedRingElements
+	UnsortedInputs
+	UnsortedOutputs
+	DuplicateKeyImages
+	DuplicateOutputPublicKey
+	TombstoneBlockExceeded
+	TombstoneBlockTooFar
+	TxFeeError
+	InvalidTransactionSignature
+	InvalidRangeProof
+	ValueNotConserved
+	MissingTxOutMembershipProof
+	InvalidTxOutMembershipProof
+)
+
+var validationRuleNames = map[ValidationRule]string{
+	NoInputs:                    "NoInputs",
+	TooManyInputs:               "TooManyInputs",
+	NoOutputs:                   "NoOutputs",
+	TooManyOutputs:              "TooManyOutputs",
+	InsufficientRingSize:        "InsufficientRingSize",
+	ExcessiveRingSize:           "ExcessiveRingSize",
+	DuplicateRingElements:       "DuplicateRingElements",
+	UnsortedRingElements:        "UnsortedRingElements",
+	UnsortedInputs:              "UnsortedInputs",
+	UnsortedOutputs:             "UnsortedOutputs",
+	DuplicateKeyImages:          "DuplicateKeyImages",
+	DuplicateOutputPublicKey:    "DuplicateOutputPublicKey",
+	TombstoneBlockExceeded:      "TombstoneBlockExceeded",
+	TombstoneBlockTooFar:        "TombstoneBlockTooFar",
+	TxFeeError:                  "TxFeeError",
+	InvalidTransactionSignature: "InvalidTransactionSignature",
+	InvalidRangeProof:           "InvalidRangeProof",
+	ValueNotConserved:           "ValueNotConserved",
+	MissingTxOutMembershipProof: "MissingTxOutMembershipProof",
+	InvalidTxOutMembershipProof: "InvalidTxOutMembershipProof",
+}
+
+func (r ValidationRule) String() string {
+	if name, ok := validationRuleNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("ValidationRule(%d)", int(r))
+}
+
+// ValidationError reports the consensus rule a transaction violates,
+// use errors.Is(err, &ValidationError{Rule: TooManyInputs}) to match a rule.
+type ValidationError struct {
+	Rule   ValidationRule
+	Detail string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Detail == "" {
+		return e.Rule.String()
+	}
+	return fmt.Sprintf("%s %s", e.Rule, e.Detail)
+}
+
+func (e *ValidationError) Is(target error) bool {
+	t, ok := target.(*ValidationError)
+	return ok && t.Rule == e.Rule
+}
+
+func validationError(rule ValidationRule, format string, a ...interface{}) error {
+	return &ValidationError{Rule: rule, Detail: fmt.Sprintf(format, a...)}
+}
+
+type validationOptions struct {
+	minimumFee     uint64
+	skipSignatures bool
+	membershipRoot *TxOutMembershipElement
+}
+
+type ValidationOption func(*validationOptions)
+
+// WithMinimumFee overrides MINIMUM_FEE, the consensus network may be
+// configured with a different fee.
+func WithMinimumFee(fee uint64) ValidationOption {
+	return func(o *validationOptions) {
+		o.minimumFee = fee
+	}
+}
+
+// WithoutSignatures skips the range proof and ring signatures checks, which
+// are by far the most expensive part of the validation.
+func WithoutSignatures() ValidationOption {
+	return func(o *validationOptions) {
+		o.skipSignatures = true
+	}
+}
+
+// WithMembershipRoot checks the membership proofs of every ring element
+// against root, the root element of the ledger, with VerifyMembershipProof.
+func WithMembershipRoot(root *TxOutMembershipElement) ValidationOption {
+	return func(o *validationOptions) {
+		o.membershipRoot = root
+	}
+}
+
+// https://github.com/mobilecoinfoundation/mobilecoin/blob/master/transaction/core/src/validation/validate.rs
+// validate, except the key images spent checks which need the ledger. The
+// membership proofs need its root too, they are only checked with
+// WithMembershipRoot.
+func ValidateTx(tx *Tx, currentBlock uint64, opts ...ValidationOption) error {
+	options := &validationOptions{minimumFee: MINIMUM_FEE}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if tx.Prefix == nil || tx.Signature == nil {
+		return errors.New("Invalid transaction")
+	}
+	prefix := tx.Prefix
+
+	if err := validateNumberOfInputs(prefix); err != nil {
+		return err
+	}
+	if err := validateNumberOfOutputs(prefix); err != nil {
+		return err
+	}
+	if err := validateRingSizes(prefix); err != nil {
+		return err
+	}
+	if err := validateRingElementsAreUnique(prefix); err != nil {
+		return err
+	}
+	if err := validateRingElementsAreSorted(prefix); err != nil {
+		return err
+	}
+	if err := validateInputsAreSorted(prefix); err != nil {
+		return err
+	}
+	if err := validateOutputsAreSorted(prefix); err != nil {
+		return err
+	}
+	if uint64(prefix.Fee) < options.minimumFee {
+		return validationError(TxFeeError, "fee %d, minimum %d", prefix.Fee, options.minimumFee)
+	}
+	if err := validateKeyImagesAreUnique(tx); err != nil {
+		return err
+	}
+	if err := validateOutputsPublicKeysAreUnique(prefix); err != nil {
+		return err
+	}
+	if err := validateTombstone(currentBlock, uint64(prefix.TombstoneBlock)); err != nil {
+		return err
+	}
+	if options.membershipRoot != nil {
+		if err := validateMembershipProofs(prefix, options.membershipRoot); err != nil {
+			return err
+		}
+	}
+	if options.skipSignatures {
+		return nil
+	}
+	return validateSignature(tx)
+}
+
+func validateNumberOfInputs(prefix *TxPrefix) error {
+	if len(prefix.Inputs) == 0 {
+		return validationError(NoInputs, "")
+	}
+	if len(prefix.Inputs) > MAX_INPUTS {
+		return validationError(TooManyInputs, "%d > %d", len(prefix.Inputs), MAX_INPUTS)
+	}
+	return nil
+}
+
+func validateNumberOfOutputs(prefix *TxPrefix) error {
+	if len(prefix.Outputs) == 0 {
+		return validationError(NoOutputs, "")
+	}
+	if len(prefix.Outputs) > MAX_OUTPUTS {
+		return validationError(TooManyOutputs, "%d > %d", len(prefix.Outputs), MAX_OUTPUTS)
+	}
+	return nil
+}
+
+func validateRingSizes(prefix *TxPrefix) error {
+	for i, input := range prefix.Inputs {
+		if len(input.Ring) < RING_SIZE {
+			return validationError(InsufficientRingSize, "input %d ring size %d", i, len(input.Ring))
+		}
+		if len(input.Ring) > RING_SIZE {
+			return validationError(ExcessiveRingSize, "input %d ring size %d", i, len(input.Ring))
+		}
+	}
+	return nil
+}
+
+func validateRingElementsAreUnique(prefix *TxPrefix) error {
+	seen := make(map[CompressedRistretto]bool)
+	for _, input := range prefix.Inputs {
+		for _, output := range input.Ring {
+			if seen[output.PublicKey] {
+				return validationError(DuplicateRingElements, "%s", output.PublicKey)
+			}
+			seen[output.PublicKey] = true
+		}
+	}
+	return nil
+}
+
+func validateRingElementsAreSorted(prefix *TxPrefix) error {
+	for i, input := range prefix.Inputs {
+		for j := 1; j < len(input.Ring); j++ {
+			if !input.Ring[j-1].PublicKey.Less(input.Ring[j].PublicKey) {
+				return validationError(UnsortedRingElements, "input %d", i)
+			}
+		}
+	}
+	return nil
+}
+
+func validateInputsAreSorted(prefix *TxPrefix) error {
+	for i := 1; i < len(prefix.Inputs); i++ {
+		if !prefix.Inputs[i-1].Ring[0].PublicKey.Less(prefix.Inputs[i].Ring[0].PublicKey) {
+			return validationError(UnsortedInputs, "input %d", i)
+		}
+	}
+	return nil
+}
+
+func validateOutputsAreSorted(prefix *TxPrefix) error {
+	for i := 1; i < len(prefix.Outputs); i++ {
+		if !prefix.Outputs[i-1].PublicKey.Less(prefix.Outputs[i].PublicKey) {
+			return validationError(UnsortedOutputs, "output %d", i)
+		}
+	}
+	return nil
+}
+
+func validateKeyImagesAreUnique(tx *Tx) error {
+	seen := make(map[string]bool)
+	for _, signature := range tx.Signature.RingSignatures {
+		if seen[signature.KeyImage] {
+			return validationError(DuplicateKeyImages, "%s", signature.KeyImage)
+		}
+		seen[signature.KeyImage] = true
+	}
+	return nil
+}
+
+func validateOutputsPublicKeysAreUnique(prefix *TxPrefix) error {
+	seen := make(map[CompressedRistretto]bool)
+	for _, output := range prefix.Outputs {
+		if seen[output.PublicKey] {
+			return validationError(DuplicateOutputPublicKey, "%s", output.PublicKey)
+		}
+		seen[output.PublicKey] = true
+	}
+	return nil
+}
+
+func validateTombstone(currentBlock, tombstoneBlock uint64) error {
+	if currentBlock >= tombstoneBlock {
+		return validationError(TombstoneBlockExceeded, "current %d, tombstone %d", currentBlock, tombstoneBlock)
+	}
+	if tombstoneBlock-currentBlock > MAX_TOMBSTONE_BLOCKS {
+		return validationError(TombstoneBlockTooFar, "current %d, tombstone %d", currentBlock, tombstoneBlock)
+	}
+	return nil
+}
+
+func validateMembershipProofs(prefix *TxPrefix, root *TxOutMembershipElement) error {
+	for i, input := range prefix.Inputs {
+		if len(input.Proofs) != len(input.Ring) {
+			return validationError(MissingTxOutMembershipProof, "input %d %d proofs for ring of %d", i, len(input.Proofs), len(input.Ring))
+		}
+		for j, txOut := range input.Ring {
+			if err := VerifyMembershipProof(txOut, input.Proofs[j], root); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateSignature(tx *Tx) error {
+	rings := make([][]*TxOut, len(tx.Prefix.Inputs))
+	for i, input := range tx.Prefix.Inputs {
+		rings[i] = input.Ring
+	}
+	outputCommitments := make([]*ristretto.Point, len(tx.Prefix.Outputs))
+	for i, output := range tx.Prefix.Outputs {
+		commitment, err := output.Amount.CommitmentPoint()
+		if err != nil {
+			return validationError(InvalidTransactionSignature, "output %d %s", i, err)
+		}
+		outputCommitments[i] = commitment
+	}
+
+	message, err := HashOfTxPrefix(tx.Prefix)
+	if err != nil {
+		return validationError(InvalidTransactionSignature, "prefix %s", err)
+	}
+	return verifyRctBulletproofs(message, rings, outputCommitments, uint64(tx.Prefix.Fee), tx.Signature)
+}
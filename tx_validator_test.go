@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/hex"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/bwesterb/go-ristretto"
+	account "github.com/jadeydi/mobilecoin-account"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAccount() (*account.Account, *account.PublicAddress) {
+	var view, spend ristretto.Scalar
+	view.Rand()
+	spend.Rand()
+	acc, err := account.NewAccountKey(hex.EncodeToString(view.Bytes()), hex.EncodeToString(spend.Bytes()))
+	if err != nil {
+		panic(err)
+	}
+	spendPrivate := acc.SubaddressSpendPrivateKey(0)
+	viewPrivate := acc.SubaddressViewPrivateKey(spendPrivate)
+	return acc, &account.PublicAddress{
+		ViewPublicKey:  hex.EncodeToString(account.PublicKey(viewPrivate).Bytes()),
+		SpendPublicKey: hex.EncodeToString(account.PublicKey(spendPrivate).Bytes()),
+	}
+}
+
+func newTestMembershipProof() *TxOutMembershipProof {
+	return &TxOutMembershipProof{Index: "0", HighestIndex: "0"}
+}
+
+// newTestInputCredential creates an output of value owned by acc and hides
+// it in a ring of RING_SIZE random outputs.
+func newTestInputCredential(acc *account.Account, address *account.PublicAddress, value uint64) *InputCredential {
+	real, _, err := CreateOutput(value, address, 0)
+	if err != nil {
+		panic(err)
+	}
+	ring := []*TxOut{real.Output}
+	for len(ring) < RING_SIZE {
+		_, decoy := newTestAccount()
+		output, _, err := CreateOutput(value, decoy, 0)
+		if err != nil {
+			panic(err)
+		}
+		ring = append(ring, output.Output)
+	}
+	sort.Slice(ring, func(i, j int) bool {
+		return ring[i].PublicKey.Less(ring[j].PublicKey)
+	})
+	proofs := make([]*TxOutMembershipProof, len(ring))
+	var realIndex int
+	for i := range ring {
+		proofs[i] = newTestMembershipProof()
+		if ring[i] == real.Output {
+			realIndex = i
+		}
+	}
+
+	private := hex.EncodeToString(acc.ViewPrivateKey.Bytes()) + hex.EncodeToString(acc.SpendPrivateKey.Bytes())
+	onetimePrivateKey, err := RecoverOnetimePrivateKey(real.Output, private, 0)
+	if err != nil {
+		panic(err)
+	}
+	return &InputCredential{
+		Ring:                ring,
+		MembershipProofs:    proofs,
+		RealIndex:           realIndex,
+		OnetimePrivateKey:   onetimePrivateKey,
+		RealOutputPublicKey: bytesToPoint(real.Output.PublicKey),
+		ViewPrivateKey:      acc.ViewPrivateKey,
+	}
+}
+
+func newTestTx(inputs []uint64, outputs []uint64, fee, tombstone uint64) *Tx {
+	acc, address := newTestAccount()
+	tb := &TransactionBuilder{TombstoneBlock: tombstone, Fee: fee}
+	for _, value := range inputs {
+		tb.InputCredentials = append(tb.InputCredentials, newTestInputCredential(acc, address, value))
+	}
+	for i, value := range outputs {
+		_, recipient := newTestAccount()
+		output, _, err := CreateOutput(value, recipient, i)
+		if err != nil {
+			panic(err)
+		}
+		tb.OutputsAndSharedSecrets = append(tb.OutputsAndSharedSecrets, output)
+	}
+	tx, err := tb.Build()
+	if err != nil {
+		panic(err)
+	}
+	return tx
+}
+
+func TestValidateTx(t *testing.T) {
+	assert := assert.New(t)
+
+	tx := newTestTx([]uint64{3 * MILLIMOB_TO_PICOMOB, 2 * MILLIMOB_TO_PICOMOB}, []uint64{4 * MILLIMOB_TO_PICOMOB, MILLIMOB_TO_PICOMOB - MINIMUM_FEE}, MINIMUM_FEE, 150)
+	assert.Nil(ValidateTx(tx, 100))
+
+	err := ValidateTx(tx, 150)
+	assert.True(errors.Is(err, &ValidationError{Rule: TombstoneBlockExceeded}))
+	err = ValidateTx(tx, 10)
+	assert.True(errors.Is(err, &ValidationError{Rule: TombstoneBlockTooFar}))
+	err = ValidateTx(tx, 100, WithMinimumFee(MINIMUM_FEE+1))
+	assert.True(errors.Is(err, &ValidationError{Rule: TxFeeError}))
+
+	tx.Prefix.Outputs[0], tx.Prefix.Outputs[1] = tx.Prefix.Outputs[1], tx.Prefix.Outputs[0]
+	err = ValidateTx(tx, 100)
+	assert.True(errors.Is(err, &ValidationError{Rule: UnsortedOutputs}))
+	tx.Prefix.Outputs[0], tx.Prefix.Outputs[1] = tx.Prefix.Outputs[1], tx.Prefix.Outputs[0]
+
+	tx.Prefix.Fee = FeeValue(MINIMUM_FEE + 1)
+	err = ValidateTx(tx, 100)
+	assert.True(errors.Is(err, &ValidationError{Rule: InvalidTransactionSignature}))
+	assert.Nil(ValidateTx(tx, 100, WithoutSignatures()))
+	tx.Prefix.Fee = FeeValue(MINIMUM_FEE)
+
+	ring := tx.Prefix.Inputs[0].Ring
+	tx.Prefix.Inputs[0].Ring = ring[:RING_SIZE-1]
+	err = ValidateTx(tx, 100)
+	assert.True(errors.Is(err, &ValidationError{Rule: InsufficientRingSize}))
+	tx.Prefix.Inputs[0].Ring = ring
+
+	keyImage := tx.Signature.RingSignatures[1].KeyImage
+	tx.Signature.RingSignatures[1].KeyImage = tx.Signature.RingSignatures[0].KeyImage
+	err = ValidateTx(tx, 100)
+	assert.True(errors.Is(err, &ValidationError{Rule: DuplicateKeyImages}))
+	tx.Signature.RingSignatures[1].KeyImage = keyImage
+
+	rangeProofs := tx.Signature.RangeProofs
+	tx.Signature.RangeProofs = rangeProofs[:len(rangeProofs)-64]
+	err = ValidateTx(tx, 100)
+	assert.True(errors.Is(err, &ValidationError{Rule: InvalidRangeProof}))
+	tx.Signature.RangeProofs = rangeProofs
+	assert.Nil(ValidateTx(tx, 100))
+}
@@ -99,3 +99,11 @@ func ScalarExpVartime(x *ristretto.Scalar, n uint64) *ristretto.Scalar {
 	}
 	return &result
 }
+
+func resizePointToPow2(vec []*ristretto.Point) []*ristretto.Point {
+	l := nextPowerOfTwo(len(vec))
+	for i := len(vec); i < l; i++ {
+		vec = append(vec, vec[i-1])
+	}
+	return vec
+}
@@ -0,0 +1,311 @@
+package api
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	WALLET_SNAPSHOT_FILE = "wallet.snapshot"
+	WALLET_JOURNAL_FILE  = "wallet.journal"
+
+	// DEFAULT_WALLET_COMPACT_AFTER is the number of journal records after
+	// which a FileWalletStore writes a new snapshot.
+	DEFAULT_WALLET_COMPACT_AFTER = 1000
+
+	walletJournalHeaderSize = 12
+)
+
+type walletSnapshot struct {
+	Sequence uint64       `json:"sequence"`
+	State    *WalletState `json:"state"`
+}
+
+type walletJournalRecord struct {
+	Sequence uint64        `json:"sequence"`
+	Update   *WalletUpdate `json:"update"`
+}
+
+// FileWalletStore is a WalletStore in a directory, a snapshot of the state
+// and an append only journal of the updates applied since. Every update is
+// one journal record, the length and crc32 of its JSON and a crc32 of both
+// followed by it, and is synced before Apply returns. A record torn by a
+// crash fails a crc32 or runs past the end of the journal and is dropped
+// when the store is opened again, so an update is either fully there or not
+// at all.
+//
+// Once the journal holds CompactAfter records, the state is written to a
+// temporary snapshot renamed over the previous one and the journal is
+// truncated. The records carry a sequence number, those already in the
+// snapshot are skipped when a crash left them in the journal. A failed
+// compaction doesn't fail the Apply, the update is in the journal, it is
+// retried by the next one and reported by CompactErr until it succeeds.
+type FileWalletStore struct {
+	CompactAfter int
+
+	mutex    sync.Mutex
+	dir      string
+	journal  *os.File
+	offset   int64
+	records  int
+	sequence uint64
+	state    *walletState
+
+	compactErr error
+}
+
+// OpenFileWalletStore opens or creates the store in dir.
+func OpenFileWalletStore(dir string) (*FileWalletStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	s := &FileWalletStore{
+		CompactAfter: DEFAULT_WALLET_COMPACT_AFTER,
+		dir:          dir,
+		state:        newWalletState(),
+	}
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	journal, err := os.OpenFile(filepath.Join(dir, WALLET_JOURNAL_FILE), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	s.journal = journal
+	if err := s.replay(); err != nil {
+		journal.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileWalletStore) Apply(update *WalletUpdate) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.journal == nil {
+		return ErrWalletStoreClosed
+	}
+	if err := s.state.validate(update); err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(&walletJournalRecord{Sequence: s.sequence + 1, Update: update})
+	if err != nil {
+		return err
+	}
+	record := make([]byte, walletJournalHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	binary.LittleEndian.PutUint32(record[8:12], crc32.ChecksumIEEE(record[0:8]))
+	copy(record[walletJournalHeaderSize:], payload)
+	_, err = s.journal.WriteAt(record, s.offset)
+	if err == nil {
+		err = s.journal.Sync()
+	}
+	if err != nil {
+		// drop what made it to the file, the update is not applied
+		s.journal.Truncate(s.offset)
+		return err
+	}
+
+	s.offset += int64(len(record))
+	s.records++
+	s.sequence++
+	s.state.apply(update)
+	if s.CompactAfter > 0 && s.records >= s.CompactAfter {
+		s.compactErr = s.compact()
+	}
+	return nil
+}
+
+func (s *FileWalletStore) Load() (*WalletState, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.journal == nil {
+		return nil, ErrWalletStoreClosed
+	}
+	return s.state.load(), nil
+}
+
+// Compact writes the snapshot and empties the journal.
+func (s *FileWalletStore) Compact() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.journal == nil {
+		return ErrWalletStoreClosed
+	}
+	s.compactErr = s.compact()
+	return s.compactErr
+}
+
+// CompactErr is the error of the last compaction, nil once one succeeds.
+func (s *FileWalletStore) CompactErr() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.compactErr
+}
+
+func (s *FileWalletStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.journal == nil {
+		return nil
+	}
+	err := s.journal.Close()
+	s.journal = nil
+	return err
+}
+
+func (s *FileWalletStore) compact() error {
+	data, err := json.Marshal(&walletSnapshot{Sequence: s.sequence, State: s.state.load()})
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, WALLET_SNAPSHOT_FILE)
+	if err := writeFileSync(path+".tmp", data); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	if err := syncDir(s.dir); err != nil {
+		return err
+	}
+
+	// a crash before the truncation leaves records the snapshot holds
+	if err := s.journal.Truncate(0); err != nil {
+		return err
+	}
+	if err := s.journal.Sync(); err != nil {
+		return err
+	}
+	s.offset = 0
+	s.records = 0
+	return nil
+}
+
+func (s *FileWalletStore) loadSnapshot() error {
+	path := filepath.Join(s.dir, WALLET_SNAPSHOT_FILE)
+	os.Remove(path + ".tmp")
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var snapshot walletSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("Invalid wallet snapshot %s: %v", path, err)
+	}
+	if snapshot.State == nil {
+		return fmt.Errorf("Invalid wallet snapshot %s without state", path)
+	}
+	update := &WalletUpdate{
+		TxOuts:      snapshot.State.TxOuts,
+		KeyImages:   snapshot.State.KeyImages,
+		PendingTxs:  snapshot.State.PendingTxs,
+		Checkpoints: snapshot.State.Checkpoints,
+	}
+	if err := s.state.validate(update); err != nil {
+		return fmt.Errorf("Invalid wallet snapshot %s: %v", path, err)
+	}
+	s.state.apply(update)
+	s.sequence = snapshot.Sequence
+	return nil
+}
+
+// replay applies the journal records after the snapshot. Only the last
+// record can be torn by a crash, it is dropped and the journal truncated
+// before it. A bad record followed by others is corruption, replay fails
+// rather than drop the updates after it. The header has its own crc32, a
+// corrupted length is not taken for a record running past the end.
+func (s *FileWalletStore) replay() error {
+	info, err := s.journal.Stat()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, WALLET_JOURNAL_FILE)
+	var header [walletJournalHeaderSize]byte
+	for {
+		_, err := s.journal.ReadAt(header[:], s.offset)
+		if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+		if crc32.ChecksumIEEE(header[0:8]) != binary.LittleEndian.Uint32(header[8:12]) {
+			if s.offset+walletJournalHeaderSize == info.Size() {
+				break
+			}
+			return fmt.Errorf("Invalid wallet journal %s, corrupted record header at offset %d", path, s.offset)
+		}
+		size := binary.LittleEndian.Uint32(header[0:4])
+		end := s.offset + walletJournalHeaderSize + int64(size)
+		if end > info.Size() {
+			break
+		}
+		payload := make([]byte, size)
+		_, err = s.journal.ReadAt(payload, s.offset+walletJournalHeaderSize)
+		if err != nil {
+			return err
+		}
+		var record walletJournalRecord
+		if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:8]) || json.Unmarshal(payload, &record) != nil {
+			if end == info.Size() {
+				break
+			}
+			return fmt.Errorf("Invalid wallet journal %s, corrupted record at offset %d", path, s.offset)
+		}
+		if record.Sequence > s.sequence {
+			if record.Sequence != s.sequence+1 {
+				return fmt.Errorf("Invalid wallet journal %s, record sequence %d after %d", path, record.Sequence, s.sequence)
+			}
+			if err := s.state.validate(record.Update); err != nil {
+				return fmt.Errorf("Invalid wallet journal %s, record %d: %v", path, record.Sequence, err)
+			}
+			s.state.apply(record.Update)
+			s.sequence = record.Sequence
+		}
+		s.offset = end
+		s.records++
+	}
+
+	if info.Size() > s.offset {
+		if err := s.journal.Truncate(s.offset); err != nil {
+			return err
+		}
+		return s.journal.Sync()
+	}
+	return nil
+}
+
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// SPENT_TRACKER_CHECKPOINT is the checkpoint of the blocks ingested by a
+// SpentTracker.
+const SPENT_TRACKER_CHECKPOINT = "spent_tracker"
+
+var ErrWalletStoreClosed = errors.New("Wallet store closed")
+
+// WalletStore persists the state of a wallet, an update is applied
+// entirely or not at all.
+type WalletStore interface {
+	Apply(update *WalletUpdate) error
+	Load() (*WalletState, error)
+	Close() error
+}
+
+// PendingTx is a transaction submitted to the network and not seen in the
+// ledger yet, BlockCount is the ledger height it was submitted at.
+type PendingTx struct {
+	Hash       string `json:"hash"`
+	Tx         *Tx    `json:"tx"`
+	BlockCount uint64 `json:"block_count"`
+}
+
+func NewPendingTx(tx *Tx, blockCount uint64) (*PendingTx, error) {
+	hash, err := tx.Hash()
+	if err != nil {
+		return nil, err
+	}
+	return &PendingTx{Hash: hash.String(), Tx: tx, BlockCount: blockCount}, nil
+}
+
+// WalletUpdate is a set of changes, TxOuts replace the outputs of the same
+// key image and Checkpoints the ones of the same name.
+type WalletUpdate struct {
+	TxOuts            []*TrackedTxOut   `json:"tx_outs,omitempty"`
+	KeyImages         map[string]uint64 `json:"key_images,omitempty"`
+	PendingTxs        []*PendingTx      `json:"pending_txs,omitempty"`
+	RemovedPendingTxs []string          `json:"removed_pending_txs,omitempty"`
+	Checkpoints       map[string]uint64 `json:"checkpoints,omitempty"`
+}
+
+func (u *WalletUpdate) IsEmpty() bool {
+	return len(u.TxOuts) == 0 && len(u.KeyImages) == 0 && len(u.PendingTxs) == 0 &&
+		len(u.RemovedPendingTxs) == 0 && len(u.Checkpoints) == 0
+}
+
+// WalletState is the whole content of a WalletStore, the outputs in the
+// order they were first stored and the pending transactions by hash.
+type WalletState struct {
+	TxOuts      []*TrackedTxOut   `json:"tx_outs"`
+	KeyImages   map[string]uint64 `json:"key_images"`
+	PendingTxs  []*PendingTx      `json:"pending_txs"`
+	Checkpoints map[string]uint64 `json:"checkpoints"`
+}
+
+// walletState applies the updates, it is shared by the stores.
+type walletState struct {
+	txOuts      map[string]*TrackedTxOut
+	order       []string
+	keyImages   map[string]uint64
+	pendingTxs  map[string]*PendingTx
+	checkpoints map[string]uint64
+}
+
+func newWalletState() *walletState {
+	return &walletState{
+		txOuts:      make(map[string]*TrackedTxOut),
+		keyImages:   make(map[string]uint64),
+		pendingTxs:  make(map[string]*PendingTx),
+		checkpoints: make(map[string]uint64),
+	}
+}
+
+// validate checks update before any change, so that apply can't fail
+// half way.
+func (s *walletState) validate(update *WalletUpdate) error {
+	if update == nil {
+		return errors.New("Invalid wallet update")
+	}
+	for _, o := range update.TxOuts {
+		if o == nil || o.UnspentTxOut == nil || o.KeyImage == "" {
+			return errors.New("Invalid wallet update tx out without key image")
+		}
+		if _, err := unspentValue(o.UnspentTxOut); err != nil {
+			return err
+		}
+	}
+	for _, tx := range update.PendingTxs {
+		if tx == nil || tx.Hash == "" {
+			return errors.New("Invalid wallet update pending tx without hash")
+		}
+	}
+	return nil
+}
+
+func (s *walletState) apply(update *WalletUpdate) {
+	for _, o := range update.TxOuts {
+		if _, ok := s.txOuts[o.KeyImage]; !ok {
+			s.order = append(s.order, o.KeyImage)
+		}
+		s.txOuts[o.KeyImage] = o.copy()
+	}
+	for keyImage, blockIndex := range update.KeyImages {
+		s.keyImages[keyImage] = blockIndex
+	}
+	for _, tx := range update.PendingTxs {
+		p := *tx
+		s.pendingTxs[tx.Hash] = &p
+	}
+	for _, hash := range update.RemovedPendingTxs {
+		delete(s.pendingTxs, hash)
+	}
+	for name, blockCount := range update.Checkpoints {
+		s.checkpoints[name] = blockCount
+	}
+}
+
+func (s *walletState) load() *WalletState {
+	state := &WalletState{
+		TxOuts:      make([]*TrackedTxOut, 0, len(s.order)),
+		KeyImages:   make(map[string]uint64, len(s.keyImages)),
+		PendingTxs:  make([]*PendingTx, 0, len(s.pendingTxs)),
+		Checkpoints: make(map[string]uint64, len(s.checkpoints)),
+	}
+	for _, keyImage := range s.order {
+		state.TxOuts = append(state.TxOuts, s.txOuts[keyImage].copy())
+	}
+	for keyImage, blockIndex := range s.keyImages {
+		state.KeyImages[keyImage] = blockIndex
+	}
+	for _, tx := range s.pendingTxs {
+		p := *tx
+		state.PendingTxs = append(state.PendingTxs, &p)
+	}
+	sort.Slice(state.PendingTxs, func(i, j int) bool {
+		return state.PendingTxs[i].Hash < state.PendingTxs[j].Hash
+	})
+	for name, blockCount := range s.checkpoints {
+		state.Checkpoints[name] = blockCount
+	}
+	return state
+}
+
+// MemoryWalletStore is a WalletStore kept in memory.
+type MemoryWalletStore struct {
+	mutex  sync.RWMutex
+	state  *walletState
+	closed bool
+}
+
+func NewMemoryWalletStore() *MemoryWalletStore {
+	return &MemoryWalletStore{state: newWalletState()}
+}
+
+func (s *MemoryWalletStore) Apply(update *WalletUpdate) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return ErrWalletStoreClosed
+	}
+	if err := s.state.validate(update); err != nil {
+		return err
+	}
+	s.state.apply(update)
+	return nil
+}
+
+func (s *MemoryWalletStore) Load() (*WalletState, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.closed {
+		return nil, ErrWalletStoreClosed
+	}
+	return s.state.load(), nil
+}
+
+func (s *MemoryWalletStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed = true
+	return nil
+}
@@ -0,0 +1,251 @@
+package api
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTrackedTxOut(value uint64, status TxOutStatus) *TrackedTxOut {
+	_, address := newTestAccount()
+	output, _, err := CreateOutput(value, address, 0)
+	if err != nil {
+		panic(err)
+	}
+	return &TrackedTxOut{
+		UnspentTxOut: &UnspentTxOut{
+			TxOut:    output.Output,
+			KeyImage: hex.EncodeToString(output.Output.PublicKey[:]),
+			Value:    strconv.FormatUint(value, 10),
+		},
+		ReceivedBlock: 10,
+		Status:        status,
+	}
+}
+
+func testWalletStore(t *testing.T, store WalletStore) {
+	assert := assert.New(t)
+
+	unspent := newTestTrackedTxOut(MILLIMOB_TO_PICOMOB, TxOutUnspent)
+	spent := newTestTrackedTxOut(2*MILLIMOB_TO_PICOMOB, TxOutUnspent)
+	tx, err := NewPendingTx(newTestTx([]uint64{3 * MILLIMOB_TO_PICOMOB}, []uint64{3*MILLIMOB_TO_PICOMOB - MINIMUM_FEE}, MINIMUM_FEE, 150), 100)
+	assert.Nil(err)
+	assert.Nil(store.Apply(&WalletUpdate{
+		TxOuts:      []*TrackedTxOut{unspent, spent},
+		KeyImages:   map[string]uint64{"00": 11},
+		PendingTxs:  []*PendingTx{tx},
+		Checkpoints: map[string]uint64{SPENT_TRACKER_CHECKPOINT: 100},
+	}))
+	spent.Status = TxOutSpent
+	spent.SpentBlock = 105
+	assert.Nil(store.Apply(&WalletUpdate{
+		TxOuts:      []*TrackedTxOut{spent},
+		KeyImages:   map[string]uint64{spent.KeyImage: 105},
+		Checkpoints: map[string]uint64{SPENT_TRACKER_CHECKPOINT: 106},
+	}))
+
+	state, err := store.Load()
+	assert.Nil(err)
+	assert.Len(state.TxOuts, 2)
+	assert.Equal(unspent.KeyImage, state.TxOuts[0].KeyImage)
+	assert.Equal(TxOutSpent, state.TxOuts[1].Status)
+	assert.Equal(uint64(105), state.TxOuts[1].SpentBlock)
+	assert.Equal(map[string]uint64{"00": 11, spent.KeyImage: 105}, state.KeyImages)
+	assert.Len(state.PendingTxs, 1)
+	hash, err := state.PendingTxs[0].Tx.Hash()
+	assert.Nil(err)
+	assert.Equal(tx.Hash, hash.String())
+	assert.Equal(uint64(106), state.Checkpoints[SPENT_TRACKER_CHECKPOINT])
+
+	// an invalid update changes nothing
+	err = store.Apply(&WalletUpdate{
+		TxOuts:    []*TrackedTxOut{unspent, {UnspentTxOut: &UnspentTxOut{TxOut: unspent.TxOut, Value: "1"}}},
+		KeyImages: map[string]uint64{"01": 12},
+	})
+	assert.NotNil(err)
+	assert.NotNil(store.Apply(&WalletUpdate{PendingTxs: []*PendingTx{{}}}))
+	assert.NotNil(store.Apply(nil))
+	state, err = store.Load()
+	assert.Nil(err)
+	assert.Len(state.KeyImages, 2)
+
+	assert.Nil(store.Apply(&WalletUpdate{RemovedPendingTxs: []string{tx.Hash}}))
+	state, err = store.Load()
+	assert.Nil(err)
+	assert.Len(state.PendingTxs, 0)
+	assert.True((&WalletUpdate{}).IsEmpty())
+}
+
+func TestMemoryWalletStore(t *testing.T) {
+	assert := assert.New(t)
+
+	store := NewMemoryWalletStore()
+	testWalletStore(t, store)
+	assert.Nil(store.Close())
+	_, err := store.Load()
+	assert.Equal(ErrWalletStoreClosed, err)
+	assert.Equal(ErrWalletStoreClosed, store.Apply(&WalletUpdate{}))
+}
+
+func TestFileWalletStore(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := filepath.Join(t.TempDir(), "wallet")
+	store, err := OpenFileWalletStore(dir)
+	assert.Nil(err)
+	testWalletStore(t, store)
+	expected, err := store.Load()
+	assert.Nil(err)
+	assert.Nil(store.Close())
+	assert.Equal(ErrWalletStoreClosed, store.Apply(&WalletUpdate{}))
+
+	journal := filepath.Join(dir, WALLET_JOURNAL_FILE)
+	data, err := os.ReadFile(journal)
+	assert.Nil(err)
+	reopen := func() *FileWalletStore {
+		store, err := OpenFileWalletStore(dir)
+		assert.Nil(err)
+		state, err := store.Load()
+		assert.Nil(err)
+		assert.Equal(len(expected.TxOuts), len(state.TxOuts))
+		for i := range state.TxOuts {
+			assert.Equal(expected.TxOuts[i].KeyImage, state.TxOuts[i].KeyImage)
+			assert.Equal(expected.TxOuts[i].Status, state.TxOuts[i].Status)
+			assert.Equal(txOutLeaf(expected.TxOuts[i].TxOut), txOutLeaf(state.TxOuts[i].TxOut))
+		}
+		assert.Equal(expected.KeyImages, state.KeyImages)
+		assert.Equal(expected.Checkpoints, state.Checkpoints)
+		assert.Len(state.PendingTxs, 0)
+		return store
+	}
+	store = reopen()
+	assert.Nil(store.Close())
+
+	// a crash in the middle of a record
+	last := newTestTrackedTxOut(MILLIMOB_TO_PICOMOB, TxOutUnspent)
+	store, err = OpenFileWalletStore(dir)
+	assert.Nil(err)
+	assert.Nil(store.Apply(&WalletUpdate{TxOuts: []*TrackedTxOut{last}}))
+	assert.Nil(store.Close())
+	full, err := os.ReadFile(journal)
+	assert.Nil(err)
+	for _, torn := range [][]byte{full[:len(data)+4], full[:len(data)+walletJournalHeaderSize], full[:len(full)-1], append(append([]byte{}, full[:len(full)-1]...), full[len(full)-1]^1)} {
+		assert.Nil(os.WriteFile(journal, torn, 0600))
+		store = reopen()
+		info, err := os.Stat(journal)
+		assert.Nil(err)
+		assert.Equal(int64(len(data)), info.Size())
+		assert.Nil(store.Close())
+	}
+
+	// corruption before valid records is not a torn tail
+	for _, i := range []int{0, 1, 2, 3, 5, 9, walletJournalHeaderSize + 1} {
+		corrupted := append([]byte{}, full...)
+		corrupted[i] ^= 1
+		assert.Nil(os.WriteFile(journal, corrupted, 0600))
+		_, err = OpenFileWalletStore(dir)
+		assert.NotNil(err)
+		info, err := os.Stat(journal)
+		assert.Nil(err)
+		assert.Equal(int64(len(full)), info.Size())
+	}
+	assert.Nil(os.WriteFile(journal, data, 0600))
+
+	// compaction, and a crash before the journal is truncated
+	store, err = OpenFileWalletStore(dir)
+	assert.Nil(err)
+	store.CompactAfter = 5
+	assert.Nil(store.Apply(&WalletUpdate{Checkpoints: map[string]uint64{"fog": 1}}))
+	expected.Checkpoints["fog"] = 1
+	assert.Nil(store.Apply(&WalletUpdate{Checkpoints: map[string]uint64{"fog": 2}}))
+	expected.Checkpoints["fog"] = 2
+	_, err = os.Stat(filepath.Join(dir, WALLET_SNAPSHOT_FILE))
+	assert.Nil(err)
+	info, err := os.Stat(journal)
+	assert.Nil(err)
+	assert.Equal(int64(0), info.Size())
+	assert.Nil(store.Close())
+	store = reopen()
+	assert.Nil(store.Close())
+
+	assert.Nil(os.WriteFile(journal, data, 0600))
+	assert.Nil(os.WriteFile(filepath.Join(dir, WALLET_SNAPSHOT_FILE+".tmp"), []byte("{"), 0600))
+	store = reopen()
+	assert.Nil(store.Apply(&WalletUpdate{Checkpoints: map[string]uint64{"fog": 3}}))
+	expected.Checkpoints["fog"] = 3
+	assert.Nil(store.Close())
+	store = reopen()
+	assert.Nil(store.Close())
+	_, err = os.Stat(filepath.Join(dir, WALLET_SNAPSHOT_FILE+".tmp"))
+	assert.True(os.IsNotExist(err))
+
+	// a failed compaction is reported and retried
+	store, err = OpenFileWalletStore(dir)
+	assert.Nil(err)
+	store.CompactAfter = 1
+	tmp := filepath.Join(dir, WALLET_SNAPSHOT_FILE+".tmp")
+	assert.Nil(os.Mkdir(tmp, 0700))
+	assert.Nil(store.Apply(&WalletUpdate{Checkpoints: map[string]uint64{"fog": 4}}))
+	expected.Checkpoints["fog"] = 4
+	assert.NotNil(store.CompactErr())
+	assert.NotNil(store.Compact())
+	assert.Nil(os.Remove(tmp))
+	assert.NotNil(store.CompactErr())
+	assert.Nil(store.Compact())
+	assert.Nil(store.CompactErr())
+	assert.Nil(store.Close())
+	store = reopen()
+	assert.Nil(store.Close())
+
+	assert.Nil(os.WriteFile(filepath.Join(dir, WALLET_SNAPSHOT_FILE), []byte("{"), 0600))
+	_, err = OpenFileWalletStore(dir)
+	assert.NotNil(err)
+}
+
+func TestSpentTrackerRestore(t *testing.T) {
+	assert := assert.New(t)
+
+	acc, address := newTestAccount()
+	private := hex.EncodeToString(acc.ViewPrivateKey.Bytes()) + hex.EncodeToString(acc.SpendPrivateKey.Bytes())
+	tracker := NewSpentTracker(private)
+	var keyImages []string
+	for i := 1; i <= 2; i++ {
+		input := newTestInputCredential(acc, address, uint64(i)*MILLIMOB_TO_PICOMOB)
+		utxo := &UnspentTxOut{TxOut: input.Ring[input.RealIndex], Value: strconv.FormatUint(uint64(i)*MILLIMOB_TO_PICOMOB, 10)}
+		assert.Nil(tracker.Add(utxo, 10))
+		keyImage, err := KeyImageOfTxOut(utxo.TxOut, private, 0)
+		assert.Nil(err)
+		keyImages = append(keyImages, keyImage)
+	}
+	ingestKeyImages(tracker, 11, []string{keyImages[0], "00"})
+
+	store, err := OpenFileWalletStore(t.TempDir())
+	assert.Nil(err)
+	update := tracker.Changes()
+	assert.Len(update.TxOuts, 2)
+	// the key images of other outputs are not stored
+	assert.Equal(map[string]uint64{keyImages[0]: 11}, update.KeyImages)
+	assert.Equal(uint64(12), update.Checkpoints[SPENT_TRACKER_CHECKPOINT])
+	assert.Nil(store.Apply(update))
+	assert.True(tracker.Changes().IsEmpty())
+
+	ingestKeyImages(tracker, 12, []string{keyImages[1]})
+	update = tracker.Changes()
+	assert.Len(update.TxOuts, 1)
+	assert.Equal(TxOutSpent, update.TxOuts[0].Status)
+	assert.Nil(store.Apply(update))
+
+	state, err := store.Load()
+	assert.Nil(err)
+	restored := NewSpentTracker(private)
+	restored.Restore(state)
+	assert.Equal(uint64(13), restored.BlockCount())
+	assert.Equal(tracker.Balance(), restored.Balance())
+	assert.Equal(uint64(12), restored.Get(keyImages[1]).SpentBlock)
+	assert.True(restored.Changes().IsEmpty())
+	assert.Nil(store.Close())
+}